@@ -101,13 +101,13 @@ func list(filePath string) (err error) {
 	if blockNums != nil {
 		// Only list blocks specified in the "--block-number" command line flag.
 		for _, blockNum := range blockNums {
-			if blockNum < len(s.MetaBlocks) {
-				listBlock(s.MetaBlocks[blockNum], blockNum)
+			if blockNum < len(s.Blocks) {
+				listBlock(s.Blocks[blockNum], blockNum)
 			}
 		}
 	} else {
 		// List all blocks.
-		for blockNum, block := range s.MetaBlocks {
+		for blockNum, block := range s.Blocks {
 			listBlock(block, blockNum)
 		}
 	}
@@ -203,7 +203,7 @@ func listApplication(app *meta.Application) {
 func listSeekTable(st *meta.SeekTable) {
 	fmt.Printf("  seek points: %d\n", len(st.Points))
 	for pointNum, point := range st.Points {
-		if point.SampleNum == meta.PlaceholderPoint {
+		if point.IsPlaceholder() {
 			fmt.Printf("    point %d: PLACEHOLDER\n", pointNum)
 		} else {
 			fmt.Printf("    point %d: sample_number=%d, stream_offset=%d, frame_samples=%d\n", pointNum, point.SampleNum, point.Offset, point.SampleCount)
@@ -221,6 +221,10 @@ func listVorbisComment(vc *meta.VorbisComment) {
 	fmt.Printf("  vendor string: %s\n", vc.Vendor)
 	fmt.Printf("  comments: %d\n", len(vc.Entries))
 	for entryNum, entry := range vc.Entries {
+		if entry.NoSeparator {
+			fmt.Printf("    comment[%d]: %s\n", entryNum, entry.Name)
+			continue
+		}
 		fmt.Printf("    comment[%d]: %s=%s\n", entryNum, entry.Name, entry.Value)
 	}
 }
@@ -297,30 +301,7 @@ func listCueSheet(cs *meta.CueSheet) {
 //        00000000: FF D8 FF E0 00 10 4A 46 49 46 00 01 01 01 00 60 ......JFIF.....`
 //        00000010: 00 60 00 00 FF DB 00 43 00 01 01 01 01 01 01 01 .`.....C........
 func listPicture(pic *meta.Picture) {
-	typeName := map[uint32]string{
-		0:  "Other",
-		1:  "32x32 pixels 'file icon' (PNG only)",
-		2:  "Other file icon",
-		3:  "Cover (front)",
-		4:  "Cover (back)",
-		5:  "Leaflet page",
-		6:  "Media (e.g. label side of CD)",
-		7:  "Lead artist/lead performer/soloist",
-		8:  "Artist/performer",
-		9:  "Conductor",
-		10: "Band/Orchestra",
-		11: "Composer",
-		12: "Lyricist/text writer",
-		13: "Recording Location",
-		14: "During recording",
-		15: "During performance",
-		16: "Movie/video screen capture",
-		17: "A bright coloured fish",
-		18: "Illustration",
-		19: "Band/artist logotype",
-		20: "Publisher/Studio logotype",
-	}
-	fmt.Printf("  type: %d (%s)\n", pic.Type, typeName[pic.Type])
+	fmt.Printf("  type: %d (%s)\n", pic.Type, pic.Type)
 	fmt.Printf("  MIME type: %s\n", pic.MIME)
 	fmt.Printf("  description: %s\n", pic.Desc)
 	fmt.Printf("  width: %d\n", pic.Width)
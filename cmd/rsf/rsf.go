@@ -26,7 +26,7 @@ func play(filePath string) (err error) {
 	if err != nil {
 		return err
 	}
-	for _, metaBlock := range s.MetaBlocks {
+	for _, metaBlock := range s.Blocks {
 		dbg.Println("meta block:")
 		pretty.Print(metaBlock)
 	}
@@ -0,0 +1,183 @@
+package flac
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/mewkiz/flac/meta"
+)
+
+// dumpTypeName maps block types to the names used by metaflac --list.
+var dumpTypeName = map[meta.BlockType]string{
+	meta.TypeStreamInfo:    "STREAMINFO",
+	meta.TypePadding:       "PADDING",
+	meta.TypeApplication:   "APPLICATION",
+	meta.TypeSeekTable:     "SEEKTABLE",
+	meta.TypeVorbisComment: "VORBIS_COMMENT",
+	meta.TypeCueSheet:      "CUESHEET",
+	meta.TypePicture:       "PICTURE",
+}
+
+// Dump writes a human-readable listing of the parsed metadata blocks of the
+// stream to w, in the format of metaflac --list. Example:
+//
+//    METADATA block #0
+//      type: 0 (STREAMINFO)
+//      is last: false
+//      length: 34
+//      minimum blocksize: 4608 samples
+//      ...
+//
+// Unlike the Go representation, the block type is listed as the 7-bit block
+// type number as stored in the stream; i.e. Header.RawType.
+func (s *Stream) Dump(w io.Writer) error {
+	d := &dumper{w: w}
+	for blockNum, block := range s.Blocks {
+		d.dumpBlock(block, blockNum)
+	}
+	return d.err
+}
+
+// A dumper writes a metaflac --list style listing, recording the first write
+// error.
+type dumper struct {
+	w   io.Writer
+	err error
+}
+
+// printf writes a formatted line to the underlying writer, unless a previous
+// write failed.
+func (d *dumper) printf(format string, a ...interface{}) {
+	if d.err != nil {
+		return
+	}
+	_, d.err = fmt.Fprintf(d.w, format, a...)
+}
+
+// dumpBlock lists the header and body of the provided metadata block.
+func (d *dumper) dumpBlock(block *meta.Block, blockNum int) {
+	d.dumpHeader(block.Header, blockNum)
+	switch body := block.Body.(type) {
+	case *meta.StreamInfo:
+		d.dumpStreamInfo(body)
+	case *meta.Application:
+		d.dumpApplication(body)
+	case *meta.SeekTable:
+		d.dumpSeekTable(body)
+	case *meta.VorbisComment:
+		d.dumpVorbisComment(body)
+	case *meta.CueSheet:
+		d.dumpCueSheet(body)
+	case *meta.Picture:
+		d.dumpPicture(body)
+	}
+}
+
+// dumpHeader lists the provided metadata block header.
+func (d *dumper) dumpHeader(header *meta.BlockHeader, blockNum int) {
+	name, ok := dumpTypeName[header.BlockType]
+	if !ok {
+		name = "UNKNOWN"
+	}
+	d.printf("METADATA block #%d\n", blockNum)
+	d.printf("  type: %d (%s)\n", header.RawType, name)
+	d.printf("  is last: %t\n", header.IsLast)
+	d.printf("  length: %d\n", header.Length)
+}
+
+// dumpStreamInfo lists the provided StreamInfo metadata block body.
+func (d *dumper) dumpStreamInfo(si *meta.StreamInfo) {
+	d.printf("  minimum blocksize: %d samples\n", si.BlockSizeMin)
+	d.printf("  maximum blocksize: %d samples\n", si.BlockSizeMax)
+	d.printf("  minimum framesize: %d bytes\n", si.FrameSizeMin)
+	d.printf("  maximum framesize: %d bytes\n", si.FrameSizeMax)
+	d.printf("  sample_rate: %d Hz\n", si.SampleRate)
+	d.printf("  channels: %d\n", si.ChannelCount)
+	d.printf("  bits-per-sample: %d\n", si.BitsPerSample)
+	d.printf("  total samples: %d\n", si.SampleCount)
+	d.printf("  MD5 signature: %s\n", si.MD5String())
+}
+
+// dumpApplication lists the provided Application metadata block body.
+func (d *dumper) dumpApplication(app *meta.Application) {
+	d.printf("  application ID: %x\n", string(app.ID))
+	d.printf("  data contents:\n")
+	if len(app.Data) > 0 {
+		d.printf("%s\n", app.Data)
+	}
+}
+
+// dumpSeekTable lists the provided SeekTable metadata block body.
+func (d *dumper) dumpSeekTable(st *meta.SeekTable) {
+	d.printf("  seek points: %d\n", len(st.Points))
+	for pointNum, point := range st.Points {
+		if point.IsPlaceholder() {
+			d.printf("    point %d: PLACEHOLDER\n", pointNum)
+		} else {
+			d.printf("    point %d: sample_number=%d, stream_offset=%d, frame_samples=%d\n", pointNum, point.SampleNum, point.Offset, point.SampleCount)
+		}
+	}
+}
+
+// dumpVorbisComment lists the provided VorbisComment metadata block body.
+func (d *dumper) dumpVorbisComment(vc *meta.VorbisComment) {
+	d.printf("  vendor string: %s\n", vc.Vendor)
+	d.printf("  comments: %d\n", len(vc.Entries))
+	for entryNum, entry := range vc.Entries {
+		if entry.NoSeparator {
+			d.printf("    comment[%d]: %s\n", entryNum, entry.Name)
+			continue
+		}
+		d.printf("    comment[%d]: %s=%s\n", entryNum, entry.Name, entry.Value)
+	}
+}
+
+// dumpCueSheet lists the provided CueSheet metadata block body.
+func (d *dumper) dumpCueSheet(cs *meta.CueSheet) {
+	d.printf("  media catalog number: %s\n", cs.MCN)
+	d.printf("  lead-in: %d\n", cs.LeadInSampleCount)
+	d.printf("  is CD: %t\n", cs.IsCompactDisc)
+	d.printf("  number of tracks: %d\n", cs.TrackCount)
+	for trackNum, track := range cs.Tracks {
+		d.printf("    track[%d]\n", trackNum)
+		d.printf("      offset: %d\n", track.Offset)
+		if trackNum == len(cs.Tracks)-1 {
+			// Lead-out track.
+			d.printf("      number: %d (LEAD-OUT)\n", track.TrackNum)
+			continue
+		}
+		d.printf("      number: %d\n", track.TrackNum)
+		d.printf("      ISRC: %s\n", track.ISRC)
+		trackType := "DATA"
+		if track.IsAudio {
+			trackType = "AUDIO"
+		}
+		d.printf("      type: %s\n", trackType)
+		d.printf("      pre-emphasis: %t\n", track.HasPreEmphasis)
+		d.printf("      number of index points: %d\n", track.TrackIndexCount)
+		for indexNum, index := range track.TrackIndexes {
+			d.printf("        index[%d]\n", indexNum)
+			d.printf("          offset: %d\n", index.Offset)
+			d.printf("          number: %d\n", index.IndexPointNum)
+		}
+	}
+}
+
+// dumpPicture lists the provided Picture metadata block body.
+func (d *dumper) dumpPicture(pic *meta.Picture) {
+	d.printf("  type: %d (%s)\n", pic.Type, pic.Type)
+	d.printf("  MIME type: %s\n", pic.MIME)
+	d.printf("  description: %s\n", pic.Desc)
+	d.printf("  width: %d\n", pic.Width)
+	d.printf("  height: %d\n", pic.Height)
+	d.printf("  depth: %d\n", pic.ColorDepth)
+	d.printf("  colors: %d", pic.ColorCount)
+	if pic.ColorCount == 0 {
+		d.printf(" (unindexed)")
+	}
+	d.printf("\n")
+	d.printf("  data length: %d\n", pic.DataLen())
+	d.printf("  data:\n")
+	d.printf("%s", hex.Dump(pic.Data))
+}
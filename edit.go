@@ -0,0 +1,221 @@
+package flac
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+// AddBlock adds the provided metadata block to the stream. A StreamInfo block
+// is placed first, and an error wrapping ErrDuplicateStreamInfo is returned if
+// the stream already contains one. Padding blocks are appended at the end, and
+// other blocks are placed after the last non-padding block; i.e. before any
+// trailing padding.
+//
+// The IsLast flags of the block headers are not updated, as they are corrected
+// by the Encoder when the metadata blocks are written.
+func (s *Stream) AddBlock(block *meta.Block) error {
+	if block == nil || block.Header == nil {
+		return errors.New("flac.Stream.AddBlock: invalid metadata block; missing block header")
+	}
+	switch block.Header.BlockType {
+	case meta.TypeStreamInfo:
+		if _, ok := s.FindBlock(meta.TypeStreamInfo); ok {
+			return fmt.Errorf("flac.Stream.AddBlock: %w", ErrDuplicateStreamInfo)
+		}
+		si, ok := block.Body.(*meta.StreamInfo)
+		if !ok {
+			return fmt.Errorf("flac.Stream.AddBlock: invalid StreamInfo block body; expected *meta.StreamInfo, got %T", block.Body)
+		}
+		s.Blocks = append([]*meta.Block{block}, s.Blocks...)
+		s.Info = si
+	case meta.TypePadding:
+		s.Blocks = append(s.Blocks, block)
+	default:
+		// Place the block before any trailing padding.
+		i := len(s.Blocks)
+		for i > 0 && s.Blocks[i-1].Header.BlockType == meta.TypePadding {
+			i--
+		}
+		s.Blocks = append(s.Blocks, nil)
+		copy(s.Blocks[i+1:], s.Blocks[i:])
+		s.Blocks[i] = block
+	}
+	return nil
+}
+
+// RemoveBlocks removes every metadata block whose type is present in the
+// provided types bitfield from the stream, and returns the number of blocks
+// removed. Stream.Info is cleared if the StreamInfo block is removed.
+func (s *Stream) RemoveBlocks(types meta.BlockType) (n int) {
+	blocks := s.Blocks[:0]
+	for _, block := range s.Blocks {
+		if block.Header.BlockType&types != 0 {
+			n++
+			continue
+		}
+		blocks = append(blocks, block)
+	}
+	// Clear the tail to allow the removed blocks to be garbage collected.
+	for i := len(blocks); i < len(s.Blocks); i++ {
+		s.Blocks[i] = nil
+	}
+	s.Blocks = blocks
+	if types&meta.TypeStreamInfo != 0 {
+		s.Info = nil
+	}
+	return n
+}
+
+// Strip removes every metadata block from the stream except the StreamInfo
+// block and the blocks whose type is present in the provided keep bitfield;
+// e.g. Strip(0) leaves only the StreamInfo block, for the smallest possible
+// metadata when written by an Encoder or UpdateFile. Note that UpdateFile
+// absorbs the freed space in a padding block when rewriting the metadata in
+// place; use Encoder to write a minimal stream.
+func (s *Stream) Strip(keep meta.BlockType) {
+	s.RemoveBlocks(^(keep | meta.TypeStreamInfo))
+}
+
+// MergeVorbisComments merges the entries of every VorbisComment block of the
+// stream into the first VorbisComment block, keeping its vendor string, and
+// removes the other VorbisComment blocks. It returns the number of blocks
+// merged and removed. The FLAC format permits only one VorbisComment block per
+// stream, but some taggers produce streams containing several. VorbisComment
+// blocks whose bodies were not parsed are left as is.
+func (s *Stream) MergeVorbisComments() (n int) {
+	var first *meta.VorbisComment
+	blocks := s.Blocks[:0]
+	for _, block := range s.Blocks {
+		vc, ok := block.Body.(*meta.VorbisComment)
+		if !ok {
+			blocks = append(blocks, block)
+			continue
+		}
+		if first == nil {
+			first = vc
+			blocks = append(blocks, block)
+			continue
+		}
+		first.Entries = append(first.Entries, vc.Entries...)
+		n++
+	}
+	for i := len(blocks); i < len(s.Blocks); i++ {
+		s.Blocks[i] = nil
+	}
+	s.Blocks = blocks
+	return n
+}
+
+// normalizedOrder specifies the position of each block type in the block order
+// produced by Stream.Normalize.
+var normalizedOrder = map[meta.BlockType]int{
+	meta.TypeStreamInfo:    0,
+	meta.TypeApplication:   1,
+	meta.TypeSeekTable:     2,
+	meta.TypeVorbisComment: 3,
+	meta.TypeCueSheet:      4,
+	meta.TypePicture:       5,
+	meta.TypeReserved:      6,
+	meta.TypePadding:       7,
+}
+
+// Normalize reorders the metadata blocks of the stream into a deterministic
+// order, as commonly produced by encoders: the StreamInfo block, followed by
+// Application, SeekTable, VorbisComment, CueSheet, Picture and reserved blocks,
+// and finally a single padding block. The relative order of blocks of the same
+// type is preserved. Multiple padding blocks are merged into one, which also
+// absorbs the headers of the merged blocks, leaving the total size of the
+// metadata unchanged.
+//
+// Normalize is never called implicitly, so that callers which require the
+// original block order to be preserved are not affected.
+func (s *Stream) Normalize() {
+	sort.SliceStable(s.Blocks, func(i, j int) bool {
+		return normalizedOrder[s.Blocks[i].Header.BlockType] < normalizedOrder[s.Blocks[j].Header.BlockType]
+	})
+
+	// Merge padding blocks.
+	var padding *meta.Block
+	blocks := s.Blocks[:0]
+	for _, block := range s.Blocks {
+		if block.Header.BlockType != meta.TypePadding {
+			blocks = append(blocks, block)
+			continue
+		}
+		if padding == nil {
+			padding = block
+			blocks = append(blocks, block)
+			continue
+		}
+		// Each merged padding block frees up its 4 byte header.
+		length := padding.Header.Length + 4 + block.Header.Length
+		if padding.SetPaddingLength(length) != nil {
+			// Keep the block if the merged length would not fit within a
+			// single padding block.
+			padding = block
+			blocks = append(blocks, block)
+		}
+	}
+	for i := len(blocks); i < len(s.Blocks); i++ {
+		s.Blocks[i] = nil
+	}
+	s.Blocks = blocks
+}
+
+// CheckOrdering returns a human-readable note for each metadata block which
+// deviates from the block order recommended for, and produced by, reference
+// encoders; i.e. the order produced by Stream.Normalize. For instance, a Picture
+// block placed before a VorbisComment block, or a SeekTable block placed after
+// a VorbisComment block. Such streams are valid, but some players only
+// recognize metadata blocks in the recommended order. CheckOrdering returns nil
+// if the blocks are in the recommended order.
+func (s *Stream) CheckOrdering() (notes []string) {
+	// Index of the preceding block with the greatest position in the
+	// recommended order.
+	maxIndex := -1
+	for i, block := range s.Blocks {
+		if maxIndex != -1 {
+			prev := s.Blocks[maxIndex]
+			if normalizedOrder[block.Header.BlockType] < normalizedOrder[prev.Header.BlockType] {
+				notes = append(notes, fmt.Sprintf("block %d (%v) is placed after block %d (%v); %v blocks should precede %v blocks", i, block.Header.BlockType, maxIndex, prev.Header.BlockType, block.Header.BlockType, prev.Header.BlockType))
+				continue
+			}
+		}
+		maxIndex = i
+	}
+	return notes
+}
+
+// Clone returns a copy of the stream with deep copies of its metadata blocks,
+// as returned by Block.Clone, so that the metadata blocks of the clone may be
+// modified without affecting the original stream. The audio frames are shared
+// with the original stream, as are the underlying reader and its position. The
+// clone does not own the underlying file of the original stream; closing the
+// clone is a no-op.
+func (s *Stream) Clone() *Stream {
+	clone := &Stream{
+		r:           s.r,
+		audioOffset: s.audioOffset,
+	}
+	if s.Blocks != nil {
+		clone.Blocks = make([]*meta.Block, len(s.Blocks))
+		for i, block := range s.Blocks {
+			clone.Blocks[i] = block.Clone()
+			if si, ok := clone.Blocks[i].Body.(*meta.StreamInfo); ok && block.Body == s.Info {
+				clone.Info = si
+			}
+		}
+	}
+	if clone.Info == nil && s.Info != nil {
+		si := *s.Info
+		clone.Info = &si
+	}
+	if s.Frames != nil {
+		clone.Frames = append([]*frame.Frame(nil), s.Frames...)
+	}
+	return clone
+}
@@ -0,0 +1,146 @@
+package flac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mewkiz/flac/meta"
+)
+
+// Errors returned by Encoder.Close and Stream.ParseBlocks for streams with an
+// invalid block order.
+var (
+	// ErrNoStreamInfo is returned when a stream does not contain a StreamInfo
+	// metadata block.
+	ErrNoStreamInfo = errors.New("flac: missing StreamInfo metadata block")
+	// ErrStreamInfoNotFirst is returned when the first metadata block of a
+	// stream is not a StreamInfo metadata block.
+	ErrStreamInfoNotFirst = errors.New("flac: first metadata block must be StreamInfo")
+)
+
+// An Encoder writes the metadata blocks of a FLAC bitstream to an output
+// stream.
+type Encoder struct {
+	// The underlying writer of the encoder.
+	w io.Writer
+	// Metadata blocks to be written.
+	blocks []*meta.Block
+}
+
+// NewEncoder returns a new encoder which writes to w. Call Encoder.AddBlock to
+// add metadata blocks and Encoder.Close to write them.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// AddBlock appends the provided metadata block to the stream. The first block
+// of the stream must be a StreamInfo block.
+func (enc *Encoder) AddBlock(block *meta.Block) {
+	enc.blocks = append(enc.blocks, block)
+}
+
+// Close writes the "fLaC" signature followed by every metadata block in the
+// order they were added. The IsLast flag is set on the final block and cleared
+// on every other block, regardless of the value of their headers. Every block is
+// validated before anything is written; an error is returned for nil blocks,
+// nil block headers, and block bodies whose type does not match the block type
+// of their header. Close does not close the underlying writer.
+func (enc *Encoder) Close() (err error) {
+	for i, block := range enc.blocks {
+		err = checkBlock(block)
+		if err != nil {
+			return fmt.Errorf("flac.Encoder.Close: block %d: %v", i, err)
+		}
+	}
+
+	// The first block type must be StreamInfo.
+	if len(enc.blocks) == 0 {
+		return ErrNoStreamInfo
+	}
+	if enc.blocks[0].Header.BlockType != meta.TypeStreamInfo {
+		return ErrStreamInfoNotFirst
+	}
+
+	// Write "fLaC" signature.
+	_, err = io.WriteString(enc.w, signature)
+	if err != nil {
+		return err
+	}
+
+	// Write metadata blocks.
+	for i, block := range enc.blocks {
+		// Write a copy of the block with the IsLast flag corrected, as to not
+		// modify the block of the caller.
+		hdr := *block.Header
+		hdr.IsLast = i == len(enc.blocks)-1
+		b := *block
+		b.Header = &hdr
+		_, err = b.WriteTo(enc.w)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkBlock verifies that the provided metadata block has a header, and that
+// the type of its body matches the block type of the header.
+func checkBlock(block *meta.Block) error {
+	if block == nil {
+		return errors.New("nil metadata block")
+	}
+	if block.Header == nil {
+		return errors.New("nil metadata block header")
+	}
+	var want meta.BlockType
+	switch block.Body.(type) {
+	case *meta.StreamInfo:
+		want = meta.TypeStreamInfo
+	case *meta.Application:
+		want = meta.TypeApplication
+	case *meta.SeekTable:
+		want = meta.TypeSeekTable
+	case *meta.VorbisComment:
+		want = meta.TypeVorbisComment
+	case *meta.CueSheet:
+		want = meta.TypeCueSheet
+	case *meta.Picture:
+		want = meta.TypePicture
+	case nil:
+		// Padding blocks have no body.
+		if block.Header.BlockType != meta.TypePadding {
+			return fmt.Errorf("unable to write %v block; block body not parsed", block.Header.BlockType)
+		}
+		return nil
+	default:
+		// Raw and custom bodies of reserved blocks.
+		want = meta.TypeReserved
+	}
+	if block.Header.BlockType != want {
+		return fmt.Errorf("block body of type %T does not match block type; expected %v, got %v", block.Body, want, block.Header.BlockType)
+	}
+	return nil
+}
+
+// WriteFile writes a complete FLAC metadata header to w; the "fLaC" signature,
+// a StreamInfo block with the provided body, and the remaining metadata blocks
+// in order, with the IsLast flag set on the final block. The provided blocks
+// must not contain a StreamInfo block. Audio frames, if any, should be written
+// to w after WriteFile returns.
+func WriteFile(w io.Writer, info *meta.StreamInfo, blocks []*meta.Block) error {
+	if info == nil {
+		return ErrNoStreamInfo
+	}
+	enc := NewEncoder(w)
+	hdr := &meta.BlockHeader{BlockType: meta.TypeStreamInfo, Length: 34}
+	enc.AddBlock(&meta.Block{Header: hdr, Body: info})
+	for i, block := range blocks {
+		if _, ok := block.Body.(*meta.StreamInfo); ok || block.Header.BlockType == meta.TypeStreamInfo {
+			return fmt.Errorf("flac.WriteFile: duplicate StreamInfo block at index %d", i)
+		}
+		enc.AddBlock(block)
+	}
+	return enc.Close()
+}
@@ -6,12 +6,22 @@
 //    - Zero or more other metadata blocks.
 //    - One or more audio frames.
 //
+// Parse and ParseFile only parse the metadata blocks and leave the stream
+// positioned at the first audio frame; call Stream.ParseFrames to parse the
+// audio frames. Parse used to take a file path and parse the audio frames as
+// well; such callers should use ParseFile followed by Stream.ParseFrames. The
+// deprecated ParseStream still parses both the metadata blocks and the audio
+// frames of an io.Reader.
+//
 // [1]: http://flac.sourceforge.net/format.html
 package flac
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -20,27 +30,87 @@ import (
 	"github.com/mewkiz/flac/meta"
 )
 
+// signature is present at the beginning of each FLAC file.
+const signature = "fLaC"
+
+// ErrInvalidMarker is returned when a stream does not start with the "fLaC"
+// signature, i.e. when it is not a FLAC stream.
+var ErrInvalidMarker = errors.New("flac: invalid signature")
+
+// ErrOggFLAC is returned when a stream starts with the "OggS" capture pattern
+// of an Ogg page instead of the "fLaC" signature, i.e. when it is a FLAC stream
+// encapsulated in an Ogg container. Ogg FLAC streams are not supported, and
+// must be demultiplexed before being parsed. ErrOggFLAC wraps ErrInvalidMarker.
+var ErrOggFLAC = fmt.Errorf("%w; Ogg FLAC is not supported", ErrInvalidMarker)
+
+// oggSignature is present at the beginning of each Ogg page.
+const oggSignature = "OggS"
+
 // A Stream is a FLAC bitstream.
 type Stream struct {
+	// The StreamInfo metadata block; also present as the first block of Blocks.
+	Info *meta.StreamInfo
 	// Metadata blocks.
-	MetaBlocks []*meta.Block
+	Blocks []*meta.Block
 	// Audio frames.
 	Frames []*frame.Frame
 	// The underlying reader of the stream.
 	r io.Reader
+	// The underlying file of the stream, if opened by Open or ParseFile; nil
+	// for readers owned by the caller.
+	f *os.File
+	// Byte offset of the first audio frame, relative to the start of the
+	// stream; updated as metadata blocks are parsed.
+	audioOffset int64
+}
+
+// ParseFile reads the provided file and returns a FLAC bitstream with all
+// metadata blocks parsed, except for the bodies of padding blocks. The file is
+// left positioned at the first audio frame; call Stream.ParseFrames to parse
+// the audio frames. Callers should close the stream when done reading from it.
+func ParseFile(filePath string) (s *Stream, err error) {
+	s, err = Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	err = s.ParseBlocks(meta.TypeAll)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
 }
 
-// Parse reads the provided file and returns a parsed FLAC bitstream. It parses
-// all metadata blocks and all audio frames. Use Open instead for more
-// granularity.
-func Parse(filePath string) (s *Stream, err error) {
+// ParseStreamInfo reads the "fLaC" signature and the first metadata block of
+// the provided file, and returns the parsed StreamInfo metadata block. The
+// remaining metadata blocks and the audio frames are never read, which makes it
+// considerably faster than ParseFile when only the audio properties of the
+// file are required. An error wrapping ErrStreamInfoNotFirst is returned if the
+// first metadata block is not a StreamInfo block.
+func ParseStreamInfo(filePath string) (si *meta.StreamInfo, err error) {
 	f, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	return ParseStream(f)
+	br := bufio.NewReaderSize(f, len(signature)+4+34)
+	err = VerifyMarker(br)
+	if err != nil {
+		return nil, err
+	}
+	block, err := meta.NewBlock(br)
+	if err != nil {
+		return nil, err
+	}
+	if block.Header.BlockType != meta.TypeStreamInfo {
+		return nil, fmt.Errorf("flac.ParseStreamInfo: %w; expected %v, got %v", ErrStreamInfoNotFirst, meta.TypeStreamInfo, block.Header.BlockType)
+	}
+	err = block.Parse()
+	if err != nil {
+		return nil, err
+	}
+	return block.Body.(*meta.StreamInfo), nil
 }
 
 // Open validates the FLAC signature of the provided file and returns a handle
@@ -52,21 +122,41 @@ func Open(filePath string) (s *Stream, err error) {
 	if err != nil {
 		return nil, err
 	}
-	return NewStream(f)
+	s, err = NewStream(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	s.f = f
+	return s, nil
 }
 
-// Close closes the underlying reader of the stream.
+// Close closes the underlying file of the stream, if it was opened by Open or
+// ParseFile. It is a no-op for streams created from a reader owned by the
+// caller, e.g. by Parse or NewStream, in which case the caller is responsible
+// for closing the reader.
 func (s *Stream) Close() error {
-	r, ok := s.r.(io.Closer)
-	if ok {
-		return r.Close()
+	if s.f == nil {
+		return nil
 	}
-	return nil
+	f := s.f
+	s.f = nil
+	return f.Close()
+}
+
+// Parse reads from the provided io.Reader and returns a FLAC bitstream with all
+// metadata blocks parsed, except for the bodies of padding blocks. The reader
+// is left positioned at the first audio frame; call Stream.ParseFrames to
+// parse the audio frames. Use NewStream instead for more granularity.
+func Parse(r io.Reader) (s *Stream, err error) {
+	return ParseContext(context.Background(), r)
 }
 
 // ParseStream reads from the provided io.Reader and returns a parsed FLAC
-// bitstream. It parses all metadata blocks and all audio frames.  Use NewStream
-// instead for more granularity.
+// bitstream. It parses all metadata blocks and all audio frames.
+//
+// Deprecated: Use Parse followed by Stream.ParseFrames, or NewStream followed
+// by Stream.Parse.
 func ParseStream(r io.Reader) (s *Stream, err error) {
 	s, err = NewStream(r)
 	if err != nil {
@@ -79,27 +169,154 @@ func ParseStream(r io.Reader) (s *Stream, err error) {
 	return s, nil
 }
 
+// ParseAt reads from the provided io.ReaderAt, of size bytes, and returns a
+// FLAC bitstream with the metadata blocks parsed selectively, based on the
+// provided types bitfield. The StreamInfo block type is always included. Each
+// metadata block header is read at the offset following the previous block,
+// so the bodies of metadata blocks whose type is not present in the types
+// bitfield are never read. The stream is left positioned at the first audio
+// frame.
+func ParseAt(ra io.ReaderAt, size int64, types meta.BlockType) (s *Stream, err error) {
+	// The StreamInfo block type is always included.
+	types |= meta.TypeStreamInfo
+
+	// Verify "fLaC" signature (size: 4 bytes).
+	sr := io.NewSectionReader(ra, 0, size)
+	var buf [4]byte
+	_, err = io.ReadFull(sr, buf[:])
+	if err != nil {
+		return nil, err
+	}
+	err = checkMarker(buf[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// Read metadata blocks.
+	s = &Stream{r: sr, audioOffset: int64(len(signature))}
+	for isFirst := true; ; isFirst = false {
+		// Read metadata block header at the offset following the previous
+		// block.
+		_, err = sr.Seek(s.audioOffset, io.SeekStart)
+		if err != nil {
+			return nil, err
+		}
+		block, err := meta.NewBlock(sr)
+		if err != nil {
+			return nil, err
+		}
+		if isFirst {
+			if block.Header.BlockType != meta.TypeStreamInfo {
+				return nil, fmt.Errorf("flac.ParseAt: %w; expected %v, got %v", ErrStreamInfoNotFirst, meta.TypeStreamInfo, block.Header.BlockType)
+			}
+		}
+		// Each metadata block consists of a 4 byte header followed by the body.
+		s.audioOffset += 4 + int64(block.Header.Length)
+		if s.audioOffset > size {
+			return nil, fmt.Errorf("flac.ParseAt: %w; %v block of %d bytes extends past the end of the stream", io.ErrUnexpectedEOF, block.Header.BlockType, block.Header.Length)
+		}
+
+		// Read metadata block body, if present in the provided types
+		// bitfield.
+		if block.Header.BlockType&types != 0 {
+			err = block.Parse()
+			if err != nil {
+				return nil, err
+			}
+		}
+		if isFirst {
+			s.Info = block.Body.(*meta.StreamInfo)
+		}
+		s.Blocks = append(s.Blocks, block)
+		if block.Header.IsLast {
+			break
+		}
+	}
+
+	// Position the stream at the first audio frame.
+	_, err = sr.Seek(s.audioOffset, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ParseContext is like Parse, but aborts parsing with the error of ctx once ctx
+// is cancelled. The context is checked between metadata blocks and during the
+// reads of metadata block bodies; it is not used once ParseContext returns.
+func ParseContext(ctx context.Context, r io.Reader) (s *Stream, err error) {
+	s, err = NewStream(newCtxReader(ctx, r))
+	if err != nil {
+		return nil, err
+	}
+	err = s.parseBlocks(ctx, meta.TypeAll, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.r = r
+	return s, nil
+}
+
+// ParseBlocks reads from the provided io.Reader and returns a FLAC bitstream
+// with the metadata blocks parsed selectively, based on the provided types
+// bitfield. The bodies of metadata blocks whose type is not present in the
+// bitfield are skipped; such blocks are still present in Stream.Blocks, but
+// with a nil body. The StreamInfo block type is always included. For instance,
+// the following parses only the Vorbis comments and pictures of a stream.
+//
+//    s, err := flac.ParseBlocks(r, meta.TypeVorbisComment|meta.TypePicture)
+//
+// The reader is left positioned at the first audio frame.
+func ParseBlocks(r io.Reader, types meta.BlockType) (s *Stream, err error) {
+	s, err = NewStream(r)
+	if err != nil {
+		return nil, err
+	}
+	err = s.ParseBlocks(types)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
 // NewStream validates the FLAC signature of the provided io.Reader and returns
 // a handle to the FLAC bitstream. Call either Stream.Parse or
 // Stream.ParseBlocks and Stream.ParseFrames to parse the metadata blocks and
 // audio frames.
 func NewStream(r io.Reader) (s *Stream, err error) {
-	// signature is present at the beginning of each FLAC file.
-	const signature = "fLaC"
+	err = VerifyMarker(r)
+	if err != nil {
+		return nil, err
+	}
 
+	s = &Stream{r: r, audioOffset: int64(len(signature))}
+	return s, nil
+}
+
+// VerifyMarker reads exactly 4 bytes from the provided io.Reader and verifies
+// that they contain the "fLaC" signature. An error wrapping ErrInvalidMarker is
+// returned if the signature is not present, which also wraps ErrOggFLAC if the
+// stream is an Ogg FLAC stream.
+func VerifyMarker(r io.Reader) (err error) {
 	// Verify "fLaC" signature (size: 4 bytes).
 	buf := make([]byte, 4)
 	_, err = io.ReadFull(r, buf)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	return checkMarker(buf)
+}
+
+// checkMarker verifies that the provided 4 bytes contain the "fLaC" signature.
+func checkMarker(buf []byte) error {
 	sig := string(buf)
+	if sig == oggSignature {
+		return fmt.Errorf("%w; expected %q, got Ogg capture pattern %q", ErrOggFLAC, signature, sig)
+	}
 	if sig != signature {
-		return nil, fmt.Errorf("flac.NewStream: invalid signature; expected %q, got %q", signature, sig)
+		return fmt.Errorf("%w; expected %q, got %q", ErrInvalidMarker, signature, sig)
 	}
-
-	s = &Stream{r: r}
-	return s, nil
+	return nil
 }
 
 // Parse reads and parses all metadata blocks and audio frames of the stream.
@@ -120,6 +337,13 @@ func (s *Stream) Parse() (err error) {
 // based on the provided types bitfield. The StreamInfo block type is always
 // included.
 func (s *Stream) ParseBlocks(types meta.BlockType) (err error) {
+	return s.parseBlocks(context.Background(), types, nil)
+}
+
+// parseBlocks reads and parses the specified metadata blocks of the stream,
+// using the provided parse options and checking for cancellation of ctx before
+// each metadata block.
+func (s *Stream) parseBlocks(ctx context.Context, types meta.BlockType, opts *meta.Options) (err error) {
 	// The StreamInfo block type is always included.
 	types |= meta.TypeStreamInfo
 
@@ -127,6 +351,11 @@ func (s *Stream) ParseBlocks(types meta.BlockType) (err error) {
 	isFirst := true
 	var isLast bool
 	for !isLast {
+		err = ctx.Err()
+		if err != nil {
+			return err
+		}
+
 		// Read metadata block header.
 		block, err := meta.NewBlock(s.r)
 		if err != nil {
@@ -139,29 +368,35 @@ func (s *Stream) ParseBlocks(types meta.BlockType) (err error) {
 		// The first block type must be StreamInfo.
 		if isFirst {
 			if block.Header.BlockType != meta.TypeStreamInfo {
-				return fmt.Errorf("flac.Stream.ParseBlocks: first block type is invalid; expected %d (StreamInfo), got %d", meta.TypeStreamInfo, block.Header.BlockType)
+				return fmt.Errorf("flac.Stream.ParseBlocks: %w; expected %v, got %v", ErrStreamInfoNotFirst, meta.TypeStreamInfo, block.Header.BlockType)
 			}
-			isFirst = false
 		}
 
 		// Check if the metadata block type is present in the provided types
 		// bitfield.
 		if block.Header.BlockType&types != 0 {
 			// Read metadata block body.
-			err = block.Parse()
+			err = block.ParseOptions(opts)
 			if err != nil {
 				return err
 			}
 		} else {
 			// Ignore metadata block body.
-			err = block.Skip()
+			_, err = block.Skip()
 			if err != nil {
 				return err
 			}
 		}
 
+		// Each metadata block consists of a 4 byte header followed by the body.
+		s.audioOffset += 4 + int64(block.Header.Length)
+
 		// Store the decoded metadata block.
-		s.MetaBlocks = append(s.MetaBlocks, block)
+		if isFirst {
+			s.Info = block.Body.(*meta.StreamInfo)
+			isFirst = false
+		}
+		s.Blocks = append(s.Blocks, block)
 	}
 
 	return nil
@@ -169,8 +404,7 @@ func (s *Stream) ParseBlocks(types meta.BlockType) (err error) {
 
 // ParseFrames reads and parses the audio frames of the stream.
 func (s *Stream) ParseFrames() (err error) {
-	// The first block is always a StreamInfo block.
-	si := s.MetaBlocks[0].Body.(*meta.StreamInfo)
+	si := s.Info
 
 	// Read audio frames.
 	// uint64 won't overflow since the max value of SampleCount is
@@ -193,3 +427,157 @@ func (s *Stream) ParseFrames() (err error) {
 
 	return nil
 }
+
+// Body returns the underlying reader of the stream, which is positioned at the
+// first audio frame once the metadata blocks have been parsed; e.g. the
+// *os.File of streams opened with ParseFile. It may be passed to external
+// decoders of audio frames. Reading from it advances the stream, so it should
+// not be combined with Stream.ParseFrames or Stream.NewFrameReader.
+//
+// Body returns the very reader passed to Parse, NewStream and related
+// functions, and the metadata blocks are parsed without reading past the last
+// metadata block. Bytes buffered by a reader such as a *bufio.Reader are
+// therefore not lost at the boundary between the metadata blocks and the audio
+// frames.
+func (s *Stream) Body() io.Reader {
+	return s.r
+}
+
+// AudioOffset returns the byte offset at which the first audio frame begins;
+// i.e. the size of the "fLaC" signature and every metadata block, including
+// their headers. It is only meaningful after the metadata blocks have been
+// parsed, and when the stream was parsed from the start of the file.
+func (s *Stream) AudioOffset() int64 {
+	return s.audioOffset
+}
+
+// SampleRate returns the sample rate in Hz of the stream, as stored in the
+// StreamInfo metadata block, or 0 if Stream.Info is nil.
+func (s *Stream) SampleRate() uint32 {
+	if s.Info == nil {
+		return 0
+	}
+	return s.Info.SampleRate
+}
+
+// Channels returns the number of channels of the stream, as stored in the
+// StreamInfo metadata block, or 0 if Stream.Info is nil.
+func (s *Stream) Channels() uint8 {
+	if s.Info == nil {
+		return 0
+	}
+	return s.Info.ChannelCount
+}
+
+// BitsPerSample returns the number of bits per sample of the stream, as stored
+// in the StreamInfo metadata block, or 0 if Stream.Info is nil.
+func (s *Stream) BitsPerSample() uint8 {
+	if s.Info == nil {
+		return 0
+	}
+	return s.Info.BitsPerSample
+}
+
+// FindBlock returns the first metadata block whose type is present in the
+// provided types bitfield, and a boolean indicating if such a block was
+// present.
+func (s *Stream) FindBlock(types meta.BlockType) (block *meta.Block, ok bool) {
+	for _, block := range s.Blocks {
+		if block.Header.BlockType&types != 0 {
+			return block, true
+		}
+	}
+	return nil, false
+}
+
+// FindBlocks returns every metadata block whose type is present in the provided
+// types bitfield.
+func (s *Stream) FindBlocks(types meta.BlockType) (blocks []*meta.Block) {
+	for _, block := range s.Blocks {
+		if block.Header.BlockType&types != 0 {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// MetaBlocks returns every metadata block of the stream except padding blocks;
+// i.e. the blocks whose type is present in the meta.TypeAll bitfield.
+func (s *Stream) MetaBlocks() []*meta.Block {
+	return s.FindBlocks(meta.TypeAll)
+}
+
+// Pictures returns the bodies of every Picture metadata block of the stream.
+func (s *Stream) Pictures() (pics []*meta.Picture) {
+	for _, block := range s.Blocks {
+		if pic, ok := block.Body.(*meta.Picture); ok {
+			pics = append(pics, pic)
+		}
+	}
+	return pics
+}
+
+// PictureByType returns the first picture of the provided type, and a boolean
+// indicating if such a picture was present.
+func (s *Stream) PictureByType(t meta.PictureType) (pic *meta.Picture, ok bool) {
+	for _, pic := range s.Pictures() {
+		if pic.Type == t {
+			return pic, true
+		}
+	}
+	return nil, false
+}
+
+// MetadataSize returns the total size in bytes of the metadata blocks of the
+// stream, including their 4 byte headers but excluding the "fLaC" signature.
+// It is computed from the parsed block headers, and includes the blocks whose
+// bodies were skipped.
+func (s *Stream) MetadataSize() (n int64) {
+	for _, block := range s.Blocks {
+		n += 4 + int64(block.Header.Length)
+	}
+	return n
+}
+
+// NumBlocks returns the number of metadata blocks of the stream, including
+// the blocks whose bodies were skipped.
+func (s *Stream) NumBlocks() int {
+	return len(s.Blocks)
+}
+
+// LastBlockValid reports whether exactly one metadata block of the stream has
+// the IsLast flag set, and it is the final block. Multiple or zero IsLast flags
+// indicate a corrupt stream.
+//
+// LastBlockValid is intended for verifying streams whose metadata blocks have
+// been added, removed or edited. Since parsing stops at the first block with
+// the IsLast flag set, LastBlockValid always reports true for freshly parsed
+// streams. Stream.AddBlock and Stream.RemoveBlocks do not update the IsLast
+// flags; they are corrected by Encoder when the metadata blocks are written.
+func (s *Stream) LastBlockValid() bool {
+	n := 0
+	for _, block := range s.Blocks {
+		if block.Header.IsLast {
+			n++
+		}
+	}
+	return n == 1 && s.Blocks[len(s.Blocks)-1].Header.IsLast
+}
+
+// PictureSize returns the total size in bytes of the picture data of the
+// Picture metadata blocks of the stream. The body length of Picture metadata
+// blocks whose bodies were skipped is used instead, as it is an upper bound of
+// their picture data.
+func (s *Stream) PictureSize() (n int64) {
+	for _, block := range s.Blocks {
+		if block.Header.BlockType != meta.TypePicture {
+			continue
+		}
+		if pic, ok := block.Body.(*meta.Picture); ok {
+			n += int64(pic.DataLen())
+		} else {
+			n += int64(block.Header.Length)
+		}
+	}
+	return n
+}
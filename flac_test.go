@@ -0,0 +1,1272 @@
+package flac_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/meta"
+)
+
+func TestClone(t *testing.T) {
+	paths := []string{
+		"meta/testdata/input-SCVPAP.flac",
+		"meta/testdata/silence.flac",
+	}
+	for _, path := range paths {
+		orig, err := flac.ParseFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer orig.Close()
+
+		// Keep a second, independently parsed copy for comparison.
+		want, err := flac.ParseFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer want.Close()
+
+		clone := orig.Clone()
+		if !reflect.DeepEqual(clone.Blocks, orig.Blocks) {
+			t.Errorf("%s: metadata blocks of clone differ from original", path)
+		}
+		if clone.Info != clone.Blocks[0].Body {
+			t.Errorf("%s: Info of clone does not refer to its StreamInfo block", path)
+		}
+
+		// Mutate the clone.
+		clone.Info.SampleRate++
+		for _, block := range clone.Blocks {
+			block.Header.Length++
+			switch body := block.Body.(type) {
+			case *meta.Application:
+				body.Data = append(body.Data[:0], 'x')
+			case *meta.SeekTable:
+				body.Points[0].Offset++
+			case *meta.VorbisComment:
+				body.Vendor += "x"
+				for i := range body.Entries {
+					body.Entries[i].Value += "x"
+				}
+			case *meta.CueSheet:
+				for i := range body.Tracks {
+					body.Tracks[i].Offset++
+					for j := range body.Tracks[i].TrackIndexes {
+						body.Tracks[i].TrackIndexes[j].Offset++
+					}
+				}
+			case *meta.Picture:
+				body.Data = append(body.Data[:0], 'x')
+			}
+		}
+		clone.Blocks = clone.Blocks[:1]
+
+		// Verify that the original is unaffected.
+		for i, block := range orig.Blocks {
+			if !reflect.DeepEqual(block.Header, want.Blocks[i].Header) || !reflect.DeepEqual(block.Body, want.Blocks[i].Body) {
+				t.Errorf("%s: metadata block %d of original modified through clone", path, i)
+			}
+		}
+		if orig.Info.SampleRate != want.Info.SampleRate {
+			t.Errorf("%s: sample rate of original modified through clone; expected %d, got %d", path, want.Info.SampleRate, orig.Info.SampleRate)
+		}
+	}
+}
+
+func TestBodyBuffered(t *testing.T) {
+	paths := []string{
+		"testdata/59996.flac",
+		"testdata/172960.flac",
+		"testdata/189983.flac",
+	}
+	for _, path := range paths {
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		br := bufio.NewReader(f)
+		s, err := flac.Parse(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := s.Body()
+		if body != io.Reader(br) {
+			t.Errorf("%s: Body returned a different reader than parsed from", path)
+		}
+
+		// Read the first byte of the first audio frame.
+		var b [1]byte
+		_, err = io.ReadFull(body, b[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := buf[s.AudioOffset()]
+		if b[0] != want {
+			t.Errorf("%s: invalid first audio byte; expected 0x%02X, got 0x%02X", path, want, b[0])
+		}
+		// Audio frames start with the frame sync code.
+		if b[0] != 0xFF {
+			t.Errorf("%s: invalid first audio byte; expected start of sync code 0xFF, got 0x%02X", path, b[0])
+		}
+	}
+}
+
+func TestTagger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flac-tagger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// testdata/59996.flac contains a VorbisComment block without padding, and
+	// meta/testdata/input-SCPAP.flac contains padding but no VorbisComment block.
+	paths := []string{
+		"testdata/59996.flac",
+		"meta/testdata/input-SCPAP.flac",
+	}
+	for _, path := range paths {
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpPath := dir + "/tagger.flac"
+		err = ioutil.WriteFile(tmpPath, buf, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tagger, err := flac.OpenTagger(tmpPath)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if err := tagger.Set("TITLE", "foo"); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		tagger.Delete("YEAR")
+		if err := tagger.Save(); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+
+		tagger, err = flac.OpenTagger(tmpPath)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if got, ok := tagger.Get("TITLE"); !ok || got != "foo" {
+			t.Errorf("%s: invalid TITLE; expected %q, got %q (present: %v)", path, "foo", got, ok)
+		}
+		if _, ok := tagger.Get("YEAR"); ok {
+			t.Errorf("%s: YEAR present after Delete", path)
+		}
+
+		// Verify that the audio frames are preserved.
+		s, err := flac.ParseFile(tmpPath)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		got, err := ioutil.ReadFile(tmpPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		orig, err := flac.Parse(bytes.NewReader(buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got[s.AudioOffset():], buf[orig.AudioOffset():]) {
+			t.Errorf("%s: audio frames modified by Save", path)
+		}
+		s.Close()
+	}
+}
+
+func TestParseLenientFieldName(t *testing.T) {
+	s, err := flac.ParseFile("testdata/59996.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	block, ok := s.FindBlock(meta.TypeVorbisComment)
+	if !ok {
+		t.Fatal("missing VorbisComment block")
+	}
+	vc := block.Body.(*meta.VorbisComment)
+	if err := vc.Set("IN\x01VALID", "foo"); err == nil {
+		t.Errorf("expected error for field name containing control character")
+	}
+	// Bypass the validation of Set to store an invalid field name.
+	vc.Entries = append(vc.Entries, meta.VorbisEntry{Name: "IN\x01VALID", Value: "foo"})
+
+	buf := new(bytes.Buffer)
+	enc := flac.NewEncoder(buf)
+	for _, block := range s.Blocks {
+		enc.AddBlock(block)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_, warnings, err := flac.ParseLenient(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("invalid Vorbis comment field name %q", "IN\x01VALID")
+	found := false
+	for _, warning := range warnings {
+		if warning.Msg == want {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("missing warning %q; got %v", want, warnings)
+	}
+}
+
+// rwsBuffer is an in-memory io.ReadWriteSeeker.
+type rwsBuffer struct {
+	buf []byte
+	off int64
+}
+
+func (b *rwsBuffer) Read(p []byte) (int, error) {
+	if b.off >= int64(len(b.buf)) {
+		return 0, io.EOF
+	}
+	n := copy(p, b.buf[b.off:])
+	b.off += int64(n)
+	return n, nil
+}
+
+func (b *rwsBuffer) Write(p []byte) (int, error) {
+	if end := b.off + int64(len(p)); end > int64(len(b.buf)) {
+		b.buf = append(b.buf, make([]byte, end-int64(len(b.buf)))...)
+	}
+	n := copy(b.buf[b.off:], p)
+	b.off += int64(n)
+	return n, nil
+}
+
+func (b *rwsBuffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += b.off
+	case io.SeekEnd:
+		offset += int64(len(b.buf))
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("rwsBuffer.Seek: negative offset %d", offset)
+	}
+	b.off = offset
+	return offset, nil
+}
+
+func TestWriteMetadata(t *testing.T) {
+	golden := []struct {
+		path  string
+		title string
+		// The modified metadata blocks fit in place.
+		fits bool
+	}{
+		// Padding block of 3174 bytes.
+		{path: "meta/testdata/input-SCPAP.flac", title: strings.Repeat("x", 1000), fits: true},
+		// VorbisComment block of 202 bytes shrinks; a padding block is added.
+		{path: "testdata/59996.flac", title: "foo", fits: true},
+		// VorbisComment block of 202 bytes grows; no padding block.
+		{path: "testdata/59996.flac", title: strings.Repeat("x", 1000), fits: false},
+	}
+	for _, g := range golden {
+		orig, err := ioutil.ReadFile(g.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rws := &rwsBuffer{buf: append([]byte(nil), orig...)}
+		s, err := flac.Parse(rws)
+		if err != nil {
+			t.Fatalf("%s: %v", g.path, err)
+		}
+		s.RemoveBlocks(meta.TypeVorbisComment)
+		vc := new(meta.VorbisComment)
+		if err := vc.Set("TITLE", g.title); err != nil {
+			t.Fatal(err)
+		}
+		block := &meta.Block{Header: &meta.BlockHeader{BlockType: meta.TypeVorbisComment, RawType: 4}, Body: vc}
+		if block.Header.Length, err = block.MarshaledLen(); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddBlock(block); err != nil {
+			t.Fatal(err)
+		}
+
+		err = flac.WriteMetadata(rws, s)
+		if !g.fits {
+			if !errors.Is(err, flac.ErrMetadataTooLarge) {
+				t.Errorf("%s: expected error wrapping ErrMetadataTooLarge, got %v", g.path, err)
+			}
+			if !bytes.Equal(rws.buf, orig) {
+				t.Errorf("%s: file modified by failed WriteMetadata", g.path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", g.path, err)
+		}
+		if len(rws.buf) != len(orig) {
+			t.Fatalf("%s: invalid file size; expected %d, got %d", g.path, len(orig), len(rws.buf))
+		}
+		got, err := flac.Parse(bytes.NewReader(rws.buf))
+		if err != nil {
+			t.Fatalf("%s: %v", g.path, err)
+		}
+		if got.AudioOffset() != s.AudioOffset() {
+			t.Errorf("%s: invalid audio offset; expected %d, got %d", g.path, s.AudioOffset(), got.AudioOffset())
+		}
+		if !bytes.Equal(rws.buf[got.AudioOffset():], orig[s.AudioOffset():]) {
+			t.Errorf("%s: audio frames modified by WriteMetadata", g.path)
+		}
+		block, ok := got.FindBlock(meta.TypeVorbisComment)
+		if !ok {
+			t.Fatalf("%s: missing VorbisComment block", g.path)
+		}
+		if title, _ := block.Body.(*meta.VorbisComment).Get("TITLE"); title != g.title {
+			t.Errorf("%s: invalid TITLE; expected %q, got %q", g.path, g.title, title)
+		}
+	}
+}
+
+func TestParseOggFLAC(t *testing.T) {
+	// Start of the first page of an Ogg FLAC stream.
+	buf := []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00")
+	_, err := flac.Parse(bytes.NewReader(buf))
+	if !errors.Is(err, flac.ErrOggFLAC) {
+		t.Errorf("expected error wrapping ErrOggFLAC, got %v", err)
+	}
+	if !errors.Is(err, flac.ErrInvalidMarker) {
+		t.Errorf("expected error wrapping ErrInvalidMarker, got %v", err)
+	}
+
+	_, err = flac.Parse(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00")))
+	if errors.Is(err, flac.ErrOggFLAC) {
+		t.Errorf("unexpected error wrapping ErrOggFLAC for non-Ogg stream; got %v", err)
+	}
+}
+
+func TestLastBlockValid(t *testing.T) {
+	s, err := flac.ParseFile("meta/testdata/input-SCPAP.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	// StreamInfo, SeekTable, CueSheet, Padding, Application and Padding.
+	if got, want := s.NumBlocks(), 6; got != want {
+		t.Errorf("invalid number of metadata blocks; expected %d, got %d", want, got)
+	}
+	if !s.LastBlockValid() {
+		t.Errorf("invalid IsLast flags of parsed stream")
+	}
+	s.Blocks[0].Header.IsLast = true
+	if s.LastBlockValid() {
+		t.Errorf("multiple IsLast flags not detected")
+	}
+	s.Blocks[0].Header.IsLast = false
+	s.Blocks[len(s.Blocks)-1].Header.IsLast = false
+	if s.LastBlockValid() {
+		t.Errorf("missing IsLast flag not detected")
+	}
+	s.Blocks[len(s.Blocks)-1].Header.IsLast = true
+
+	// Removing the trailing padding block leaves no block with the IsLast flag
+	// set, until the metadata blocks are written by Encoder.
+	s.RemoveBlocks(meta.TypePadding)
+	if s.LastBlockValid() {
+		t.Errorf("missing IsLast flag not detected after RemoveBlocks")
+	}
+	buf := new(bytes.Buffer)
+	enc := flac.NewEncoder(buf)
+	for _, block := range s.Blocks {
+		enc.AddBlock(block)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := flac.ParseBlocks(buf, meta.TypeAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.LastBlockValid() {
+		t.Errorf("invalid IsLast flags of stream written by Encoder")
+	}
+
+	// Adding a padding block after the last block leaves the IsLast flag set
+	// on a block other than the final one.
+	if err := got.AddBlock(meta.NewPadding(10)); err != nil {
+		t.Fatal(err)
+	}
+	if got.LastBlockValid() {
+		t.Errorf("misplaced IsLast flag not detected after AddBlock")
+	}
+}
+
+func TestWalkBlocks(t *testing.T) {
+	const path = "meta/testdata/input-SCPAP.flac"
+	s, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var types []meta.BlockType
+	r := bytes.NewReader(buf)
+	err = flac.WalkBlocks(r, func(block *meta.Block) error {
+		types = append(types, block.Header.BlockType)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []meta.BlockType
+	for _, block := range s.Blocks {
+		want = append(want, block.Header.BlockType)
+	}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("invalid block types; expected %v, got %v", want, types)
+	}
+	if off := int64(len(buf)) - int64(r.Len()); off != s.AudioOffset() {
+		t.Errorf("invalid reader position; expected %d, got %d", s.AudioOffset(), off)
+	}
+
+	// Stop at the SeekTable block.
+	n := 0
+	err = flac.WalkBlocks(bytes.NewReader(buf), func(block *meta.Block) error {
+		n++
+		if block.Header.BlockType == meta.TypeSeekTable {
+			return flac.StopParsing
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("unexpected error for StopParsing; %v", err)
+	}
+	if n != 2 {
+		t.Errorf("invalid number of callbacks; expected 2, got %d", n)
+	}
+
+	// Errors of the callback are returned as is.
+	errFoo := errors.New("foo")
+	err = flac.WalkBlocks(bytes.NewReader(buf), func(block *meta.Block) error {
+		return errFoo
+	})
+	if err != errFoo {
+		t.Errorf("invalid error; expected %v, got %v", errFoo, err)
+	}
+
+	// Errors wrapping StopParsing also stop parsing.
+	n = 0
+	err = flac.WalkBlocks(bytes.NewReader(buf), func(block *meta.Block) error {
+		n++
+		return fmt.Errorf("done: %w", flac.StopParsing)
+	})
+	if err != nil {
+		t.Errorf("unexpected error for wrapped StopParsing; %v", err)
+	}
+	if n != 1 {
+		t.Errorf("invalid number of callbacks; expected 1, got %d", n)
+	}
+
+	// First metadata block not StreamInfo; replace the StreamInfo block type
+	// number with that of a Vorbis comment.
+	bad := append([]byte(nil), buf...)
+	bad[4] = bad[4]&0x80 | 4
+	err = flac.WalkBlocks(bytes.NewReader(bad), func(block *meta.Block) error {
+		return nil
+	})
+	if !errors.Is(err, flac.ErrStreamInfoNotFirst) {
+		t.Fatalf("invalid error; expected %v, got %v", flac.ErrStreamInfoNotFirst, err)
+	}
+	if want := "expected stream info, got vorbis comment"; !strings.Contains(err.Error(), want) {
+		t.Errorf("invalid error message; expected to contain %q, got %q", want, err.Error())
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	paths := []string{
+		"testdata/59996.flac",
+		"meta/testdata/input-SCPAP.flac",
+		"meta/testdata/input-SCVPAP.flac",
+		"meta/testdata/input-VA.flac",
+	}
+	for _, path := range paths {
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf)
+	}
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		// Parse must return either a stream or an error, without panicking.
+		s, err := flac.Parse(bytes.NewReader(buf))
+		if err == nil && s == nil {
+			t.Errorf("nil stream without error")
+		}
+	})
+}
+
+func TestStreamInfoAccessors(t *testing.T) {
+	s, err := flac.ParseFile("testdata/59996.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got, want := s.SampleRate(), s.Info.SampleRate; got != want {
+		t.Errorf("invalid sample rate; expected %d, got %d", want, got)
+	}
+	if got, want := s.Channels(), s.Info.ChannelCount; got != want {
+		t.Errorf("invalid number of channels; expected %d, got %d", want, got)
+	}
+	if got, want := s.BitsPerSample(), s.Info.BitsPerSample; got != want {
+		t.Errorf("invalid bits per sample; expected %d, got %d", want, got)
+	}
+
+	// Zero values are returned for streams without a StreamInfo block.
+	s.Info = nil
+	if s.SampleRate() != 0 || s.Channels() != 0 || s.BitsPerSample() != 0 {
+		t.Errorf("expected zero values for nil Stream.Info; got %d, %d and %d", s.SampleRate(), s.Channels(), s.BitsPerSample())
+	}
+}
+
+func TestMergeVorbisComments(t *testing.T) {
+	s, err := flac.ParseFile("testdata/59996.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	block, ok := s.FindBlock(meta.TypeVorbisComment)
+	if !ok {
+		t.Fatal("missing VorbisComment block")
+	}
+	vc := block.Body.(*meta.VorbisComment)
+	vendor := vc.Vendor
+	want := append([]meta.VorbisEntry(nil), vc.Entries...)
+	if n := s.MergeVorbisComments(); n != 0 {
+		t.Errorf("invalid number of merged blocks for single VorbisComment block; expected 0, got %d", n)
+	}
+
+	// Add two duplicate VorbisComment blocks.
+	for _, title := range []string{"foo", "bar"} {
+		dup := &meta.VorbisComment{Vendor: "other vendor"}
+		if err := dup.Add("TITLE", title); err != nil {
+			t.Fatal(err)
+		}
+		s.Blocks = append(s.Blocks, &meta.Block{Header: &meta.BlockHeader{BlockType: meta.TypeVorbisComment}, Body: dup})
+		want = append(want, meta.VorbisEntry{Name: "TITLE", Value: title})
+	}
+	if n := s.MergeVorbisComments(); n != 2 {
+		t.Errorf("invalid number of merged blocks; expected 2, got %d", n)
+	}
+	blocks := s.FindBlocks(meta.TypeVorbisComment)
+	if len(blocks) != 1 {
+		t.Fatalf("invalid number of VorbisComment blocks; expected 1, got %d", len(blocks))
+	}
+	got := blocks[0].Body.(*meta.VorbisComment)
+	if got.Vendor != vendor {
+		t.Errorf("invalid vendor string; expected %q, got %q", vendor, got.Vendor)
+	}
+	if !reflect.DeepEqual(got.Entries, want) {
+		t.Errorf("invalid merged entries; expected %v, got %v", want, got.Entries)
+	}
+}
+
+func TestSeekOffset(t *testing.T) {
+	golden := []struct {
+		path        string
+		sample      uint64
+		offset      int64
+		firstSample uint64
+	}{
+		// Seek table with seek points at samples 0 and 4608.
+		{path: "meta/testdata/input-SCPAP.flac", sample: 0, offset: 0, firstSample: 0},
+		{path: "meta/testdata/input-SCPAP.flac", sample: 4607, offset: 0, firstSample: 0},
+		{path: "meta/testdata/input-SCPAP.flac", sample: 4608, offset: 14, firstSample: 4608},
+		{path: "meta/testdata/input-SCPAP.flac", sample: 5879, offset: 14, firstSample: 4608},
+		// No seek table.
+		{path: "testdata/172960.flac", sample: 4095, offset: 0, firstSample: 0},
+		{path: "testdata/172960.flac", sample: 5000, offset: 8283, firstSample: 4096},
+		{path: "testdata/172960.flac", sample: 43682, offset: 86656, firstSample: 40960},
+	}
+	for _, g := range golden {
+		buf, err := ioutil.ReadFile(g.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, withSeekTable := range []bool{true, false} {
+			s, err := flac.Parse(bytes.NewReader(buf))
+			if err != nil {
+				t.Fatalf("%s: %v", g.path, err)
+			}
+			if !withSeekTable {
+				s.RemoveBlocks(meta.TypeSeekTable)
+			}
+			offset, firstSample, err := s.SeekOffset(g.sample)
+			if err != nil {
+				t.Errorf("%s: sample %d: %v", g.path, g.sample, err)
+				continue
+			}
+			if offset != g.offset || firstSample != g.firstSample {
+				t.Errorf("%s: sample %d: invalid frame; expected offset %d and first sample %d, got %d and %d", g.path, g.sample, g.offset, g.firstSample, offset, firstSample)
+			}
+			if b := buf[s.AudioOffset()+offset]; b != 0xFF {
+				t.Errorf("%s: sample %d: invalid first byte of frame; expected start of sync code 0xFF, got 0x%02X", g.path, g.sample, b)
+			}
+		}
+	}
+
+	s, err := flac.ParseFile("testdata/172960.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, _, err := s.SeekOffset(s.Info.SampleCount); err == nil {
+		t.Errorf("expected error for sample number beyond end of stream")
+	}
+	if _, _, err := s.SeekOffset(5000); err != nil {
+		t.Fatal(err)
+	}
+	// The stream must still be positioned at the first audio frame.
+	var b [1]byte
+	if _, err := io.ReadFull(s.Body(), b[:]); err != nil {
+		t.Fatal(err)
+	}
+	if b[0] != 0xFF {
+		t.Errorf("invalid position after SeekOffset; expected start of sync code 0xFF, got 0x%02X", b[0])
+	}
+}
+
+func TestStrip(t *testing.T) {
+	s, err := flac.ParseFile("meta/testdata/input-SCVPAP.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Strip(meta.TypeVorbisComment)
+	for _, block := range s.Blocks {
+		switch block.Header.BlockType {
+		case meta.TypeStreamInfo, meta.TypeVorbisComment:
+		default:
+			t.Errorf("unexpected %v block after Strip", block.Header.BlockType)
+		}
+	}
+	if _, ok := s.FindBlock(meta.TypeVorbisComment); !ok {
+		t.Errorf("missing VorbisComment block after Strip")
+	}
+
+	s.Strip(0)
+	if len(s.Blocks) != 1 || s.Blocks[0].Header.BlockType != meta.TypeStreamInfo {
+		t.Fatalf("invalid metadata blocks after Strip(0); expected only StreamInfo, got %d blocks", len(s.Blocks))
+	}
+	if s.Info == nil {
+		t.Errorf("Stream.Info cleared by Strip")
+	}
+	buf := new(bytes.Buffer)
+	enc := flac.NewEncoder(buf)
+	enc.AddBlock(s.Blocks[0])
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// "fLaC" signature, block header and StreamInfo body.
+	if got, want := buf.Len(), 4+4+34; got != want {
+		t.Errorf("invalid size of stripped metadata; expected %d, got %d", want, got)
+	}
+}
+
+func TestParseNoSeparator(t *testing.T) {
+	s, err := flac.ParseFile("testdata/59996.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	block, ok := s.FindBlock(meta.TypeVorbisComment)
+	if !ok {
+		t.Fatal("missing VorbisComment block")
+	}
+	vc := block.Body.(*meta.VorbisComment)
+	vc.Entries = append(vc.Entries, meta.VorbisEntry{Name: "garbage", NoSeparator: true})
+	buf := new(bytes.Buffer)
+	enc := flac.NewEncoder(buf)
+	for _, block := range s.Blocks {
+		enc.AddBlock(block)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The entry is preserved by Parse.
+	got, err := flac.Parse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ = got.FindBlock(meta.TypeVorbisComment)
+	entries := block.Body.(*meta.VorbisComment).Entries
+	if last := entries[len(entries)-1]; !last.NoSeparator || last.Name != "garbage" {
+		t.Errorf("entry without separator not preserved; got %+v", last)
+	}
+
+	_, err = flac.ParseStrict(bytes.NewReader(buf.Bytes()))
+	if !errors.Is(err, flac.ErrInvalidFieldName) {
+		t.Errorf("expected error wrapping ErrInvalidFieldName, got %v", err)
+	}
+	_, warnings, err := flac.ParseLenient(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("Vorbis comment vector %q lacks a '=' separator", "garbage")
+	found := false
+	for _, warning := range warnings {
+		if warning.Msg == want {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("missing warning %q; got %v", want, warnings)
+	}
+}
+
+func TestUpdateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flac-update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	golden := []struct {
+		path string
+		// The metadata is rewritten in place.
+		inPlace bool
+	}{
+		// Padding block of 3174 bytes.
+		{path: "meta/testdata/input-SCPAP.flac", inPlace: true},
+		// VorbisComment block of 202 bytes, and no padding block.
+		{path: "testdata/59996.flac", inPlace: false},
+	}
+	for _, g := range golden {
+		orig, err := ioutil.ReadFile(g.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := flac.Parse(bytes.NewReader(orig))
+		if err != nil {
+			t.Fatalf("%s: %v", g.path, err)
+		}
+		audio := orig[s.AudioOffset():]
+
+		tmpPath := filepath.Join(dir, "update.flac")
+		if err := ioutil.WriteFile(tmpPath, orig, 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Use a file mode unaffected by the umask.
+		const mode = 0604
+		if err := os.Chmod(tmpPath, mode); err != nil {
+			t.Fatal(err)
+		}
+		before, err := os.Stat(tmpPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Add a tag of 1000 bytes, which fits within the padding of
+		// input-SCPAP.flac but not in 59996.flac.
+		title := strings.Repeat("x", 1000)
+		err = flac.UpdateFile(tmpPath, func(s *flac.Stream) error {
+			vc := new(meta.VorbisComment)
+			if err := vc.Set("TITLE", title); err != nil {
+				return err
+			}
+			block := &meta.Block{Header: &meta.BlockHeader{BlockType: meta.TypeVorbisComment, RawType: 4}, Body: vc}
+			var err error
+			if block.Header.Length, err = block.MarshaledLen(); err != nil {
+				return err
+			}
+			s.RemoveBlocks(meta.TypeVorbisComment)
+			return s.AddBlock(block)
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", g.path, err)
+		}
+
+		after, err := os.Stat(tmpPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := after.Mode().Perm(); got != mode {
+			t.Errorf("%s: file mode not preserved; expected %v, got %v", g.path, os.FileMode(mode), got)
+		}
+		if got := os.SameFile(before, after); got != g.inPlace {
+			t.Errorf("%s: invalid update strategy; expected in place %v, got %v", g.path, g.inPlace, got)
+		}
+		if g.inPlace && after.Size() != before.Size() {
+			t.Errorf("%s: invalid file size after in place update; expected %d, got %d", g.path, before.Size(), after.Size())
+		}
+
+		buf, err := ioutil.ReadFile(tmpPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := flac.Parse(bytes.NewReader(buf))
+		if err != nil {
+			t.Fatalf("%s: %v", g.path, err)
+		}
+		if !bytes.Equal(buf[got.AudioOffset():], audio) {
+			t.Errorf("%s: audio frames modified by UpdateFile", g.path)
+		}
+		block, ok := got.FindBlock(meta.TypeVorbisComment)
+		if !ok {
+			t.Fatalf("%s: missing VorbisComment block", g.path)
+		}
+		if value, _ := block.Body.(*meta.VorbisComment).Get("TITLE"); value != title {
+			t.Errorf("%s: invalid TITLE; expected %d bytes, got %d", g.path, len(title), len(value))
+		}
+		// No temporary files are left behind.
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 1 {
+			t.Errorf("%s: invalid number of files in directory; expected 1, got %d", g.path, len(files))
+		}
+	}
+}
+
+// readAtRecorder records the byte ranges read through ReadAt.
+type readAtRecorder struct {
+	ra    io.ReaderAt
+	reads [][2]int64
+}
+
+func (r *readAtRecorder) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.ra.ReadAt(p, off)
+	r.reads = append(r.reads, [2]int64{off, off + int64(n)})
+	return n, err
+}
+
+func TestParseAt(t *testing.T) {
+	const path = "meta/testdata/input-SCPAP.flac"
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := flac.Parse(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &readAtRecorder{ra: bytes.NewReader(buf)}
+	s, err := flac.ParseAt(r, int64(len(buf)), meta.TypeCueSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Blocks) != len(want.Blocks) {
+		t.Fatalf("invalid number of metadata blocks; expected %d, got %d", len(want.Blocks), len(s.Blocks))
+	}
+	if !reflect.DeepEqual(s.Info, want.Info) {
+		t.Errorf("StreamInfo mismatch; expected %#v, got %#v", want.Info, s.Info)
+	}
+	off := int64(4)
+	for i, block := range s.Blocks {
+		start, end := off+4, off+4+int64(block.Header.Length)
+		off = end
+		switch block.Header.BlockType {
+		case meta.TypeStreamInfo, meta.TypeCueSheet:
+			if !reflect.DeepEqual(block.Body, want.Blocks[i].Body) {
+				t.Errorf("block %d: %v body mismatch", i, block.Header.BlockType)
+			}
+			continue
+		}
+		if block.Body != nil {
+			t.Errorf("block %d: unexpected %v body; expected nil", i, block.Header.BlockType)
+		}
+		for _, read := range r.reads {
+			if read[0] < end && read[1] > start {
+				t.Errorf("block %d: body of skipped %v block read at [%d, %d)", i, block.Header.BlockType, read[0], read[1])
+			}
+		}
+	}
+	if got := s.AudioOffset(); got != want.AudioOffset() {
+		t.Errorf("invalid audio offset; expected %d, got %d", want.AudioOffset(), got)
+	}
+	// The stream must be positioned at the first audio frame.
+	var b [1]byte
+	if _, err := io.ReadFull(s.Body(), b[:]); err != nil {
+		t.Fatal(err)
+	}
+	if b[0] != 0xFF {
+		t.Errorf("invalid position after ParseAt; expected start of sync code 0xFF, got 0x%02X", b[0])
+	}
+
+	// A metadata block extending past the end of the stream is an error.
+	if _, err := flac.ParseAt(bytes.NewReader(buf), want.AudioOffset()-1, meta.TypeAll); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected io.ErrUnexpectedEOF for truncated metadata, got %v", err)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	s, err := flac.ParseFile("testdata/59996.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	info := s.Blocks[0]
+	vc, ok := s.FindBlock(meta.TypeVorbisComment)
+	if !ok {
+		t.Fatal("missing VorbisComment block")
+	}
+	padding := &meta.Block{Header: &meta.BlockHeader{BlockType: meta.TypePadding, Length: 10, IsLast: true}}
+	golden := []struct {
+		blocks []*meta.Block
+		want   error
+		fails  bool
+	}{
+		{blocks: nil, want: flac.ErrNoStreamInfo},
+		{blocks: []*meta.Block{vc, info}, want: flac.ErrStreamInfoNotFirst},
+		{blocks: []*meta.Block{info, nil}, fails: true},
+		{blocks: []*meta.Block{info, {Body: vc.Body}}, fails: true},
+		// VorbisComment body in a Picture block.
+		{blocks: []*meta.Block{info, {Header: &meta.BlockHeader{BlockType: meta.TypePicture}, Body: vc.Body}}, fails: true},
+		// Unparsed body.
+		{blocks: []*meta.Block{info, {Header: &meta.BlockHeader{BlockType: meta.TypeVorbisComment}}}, fails: true},
+		{blocks: []*meta.Block{info, padding, vc}},
+	}
+	for i, g := range golden {
+		buf := new(bytes.Buffer)
+		enc := flac.NewEncoder(buf)
+		for _, block := range g.blocks {
+			enc.AddBlock(block)
+		}
+		err := enc.Close()
+		switch {
+		case g.want != nil:
+			if !errors.Is(err, g.want) {
+				t.Errorf("i=%d: error mismatch; expected %v, got %v", i, g.want, err)
+			}
+		case g.fails:
+			if err == nil {
+				t.Errorf("i=%d: expected error", i)
+			}
+		default:
+			if err != nil {
+				t.Errorf("i=%d: unexpected error; %v", i, err)
+				continue
+			}
+			// The IsLast flag is only set on the final block.
+			got, err := flac.Parse(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Errorf("i=%d: unable to parse encoded stream; %v", i, err)
+				continue
+			}
+			if len(got.Blocks) != len(g.blocks) {
+				t.Errorf("i=%d: invalid number of metadata blocks; expected %d, got %d", i, len(g.blocks), len(got.Blocks))
+			}
+			for j, block := range got.Blocks {
+				if want := j == len(got.Blocks)-1; block.Header.IsLast != want {
+					t.Errorf("i=%d: block %d: invalid IsLast flag; expected %t, got %t", i, j, want, block.Header.IsLast)
+				}
+			}
+			// The headers of the caller are left unmodified.
+			if !padding.Header.IsLast {
+				t.Errorf("i=%d: IsLast flag of added block modified by Close", i)
+			}
+		}
+		if err != nil && buf.Len() != 0 {
+			t.Errorf("i=%d: %d bytes written before error", i, buf.Len())
+		}
+	}
+}
+
+func TestFrameReader(t *testing.T) {
+	s, err := flac.ParseFile("testdata/59996.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	fr, err := s.NewFrameReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var samples uint64
+	prevOffset := int64(-1)
+	for {
+		hdr, err := fr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fr.Offset() <= prevOffset {
+			t.Errorf("invalid offset of frame at sample %d; expected > %d, got %d", samples, prevOffset, fr.Offset())
+		}
+		prevOffset = fr.Offset()
+		samples += uint64(hdr.SampleCount)
+	}
+	if samples != s.Info.SampleCount {
+		t.Errorf("invalid total number of samples; expected %d, got %d", s.Info.SampleCount, samples)
+	}
+}
+
+// errFailingReader is returned by failingReader.
+var errFailingReader = errors.New("read failure")
+
+// failingReader fails every read past the byte offset failAt.
+type failingReader struct {
+	*bytes.Reader
+	failAt int64
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	pos := r.Size() - int64(r.Len())
+	if pos >= r.failAt {
+		return 0, errFailingReader
+	}
+	if max := r.failAt - pos; int64(len(p)) > max {
+		p = p[:max]
+	}
+	return r.Reader.Read(p)
+}
+
+func TestEstimateDuration(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/59996.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := flac.Parse(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := s.Info.Duration()
+	got, err := s.EstimateDuration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("invalid duration; expected %v, got %v", want, got)
+	}
+
+	// Scan the frame headers when the total number of samples is unknown.
+	s.Info.SampleCount = 0
+	got, err = s.EstimateDuration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("invalid duration from scanned frame headers; expected %v, got %v", want, got)
+	}
+	// The stream must still be positioned at the first audio frame.
+	var b [1]byte
+	if _, err := io.ReadFull(s.Body(), b[:]); err != nil {
+		t.Fatal(err)
+	}
+	if b[0] != 0xFF {
+		t.Errorf("invalid position after EstimateDuration; expected start of sync code 0xFF, got 0x%02X", b[0])
+	}
+
+	// Frames already read by the caller are counted.
+	r := bytes.NewReader(buf)
+	s, err = flac.Parse(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Info.SampleCount = 0
+	if _, err := io.CopyN(ioutil.Discard, r, 10000); err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.EstimateDuration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("invalid duration from partially read stream; expected %v, got %v", want, got)
+	}
+	if pos := int64(len(buf)) - int64(r.Len()); pos != s.AudioOffset()+10000 {
+		t.Errorf("invalid position after EstimateDuration; expected %d, got %d", s.AudioOffset()+10000, pos)
+	}
+
+	// The position is restored when scanning the frame headers fails.
+	fr := &failingReader{Reader: bytes.NewReader(buf), failAt: int64(len(buf)) - 100}
+	s, err = flac.Parse(fr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Info.SampleCount = 0
+	if _, err := s.EstimateDuration(); err != errFailingReader {
+		t.Errorf("invalid error; expected %v, got %v", errFailingReader, err)
+	}
+	if pos := int64(len(buf)) - int64(fr.Len()); pos != s.AudioOffset() {
+		t.Errorf("invalid position after failed EstimateDuration; expected %d, got %d", s.AudioOffset(), pos)
+	}
+
+	// Frame headers cannot be scanned without an io.Seeker.
+	s, err = flac.Parse(io.MultiReader(bytes.NewReader(buf)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Info.SampleCount = 0
+	if _, err := s.EstimateDuration(); err == nil {
+		t.Errorf("expected error for unseekable stream of unknown length")
+	}
+}
+
+func TestStreamInfoNotFirst(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/59996.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Replace the StreamInfo block type number with that of a Vorbis comment.
+	buf[4] = buf[4]&0x80 | 4
+	dir, err := ioutil.TempDir("", "flac-streaminfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bad.flac")
+	if err := ioutil.WriteFile(path, buf, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	const want = "expected stream info, got vorbis comment"
+	_, err1 := flac.ParseStreamInfo(path)
+	_, err2 := flac.ParseBlocks(bytes.NewReader(buf), meta.TypeAll)
+	_, err3 := flac.ParseAt(bytes.NewReader(buf), int64(len(buf)), meta.TypeAll)
+	for _, err := range []error{err1, err2, err3} {
+		if !errors.Is(err, flac.ErrStreamInfoNotFirst) {
+			t.Errorf("invalid error; expected %v, got %v", flac.ErrStreamInfoNotFirst, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("invalid error message; expected to contain %q, got %q", want, err.Error())
+		}
+	}
+}
+
+func TestScanDir(t *testing.T) {
+	const root = "meta/testdata"
+	want := make(map[string]int)
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() && filepath.Ext(path) == ".flac" {
+			want[path] = 1
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := flac.ScanDir(root, meta.TypeVorbisComment, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]int)
+	for result := range results {
+		got[result.Path]++
+		if result.Err != nil {
+			continue
+		}
+		if result.Stream == nil || result.Stream.Info == nil {
+			t.Errorf("%s: missing StreamInfo of parsed stream", result.Path)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid scanned files; expected %v, got %v", want, got)
+	}
+
+	if _, err := flac.ScanDir(filepath.Join(root, "missing"), meta.TypeAll, 0); err == nil {
+		t.Errorf("expected error for missing root directory")
+	}
+}
+
+func TestDump(t *testing.T) {
+	s, err := flac.ParseFile("testdata/59996.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	// Reserved block type number 9.
+	reserved := &meta.Block{Header: &meta.BlockHeader{BlockType: meta.TypeReserved, RawType: 9, Length: 3}, Body: []byte("foo")}
+	s.Blocks = append(s.Blocks, reserved)
+	const want = `METADATA block #0
+  type: 0 (STREAMINFO)
+  is last: false
+  length: 34
+  minimum blocksize: 4096 samples
+  maximum blocksize: 4096 samples
+  minimum framesize: 17605 bytes
+  maximum framesize: 17800 bytes
+  sample_rate: 44100 Hz
+  channels: 2
+  bits-per-sample: 24
+  total samples: 8192
+  MD5 signature: 95bae5e2c745bb3ca95ca3b135c943f4
+METADATA block #1
+  type: 4 (VORBIS_COMMENT)
+  is last: true
+  length: 202
+  vendor string: reference libFLAC 1.2.1 20070917
+  comments: 4
+    comment[0]: Description=Waving a bamboo staff
+    comment[1]: YEAR=2008
+    comment[2]: ARTIST=qubodup aka Iwan Gabovitch | qubodup@gmail.com
+    comment[3]: COMMENTS=I release this file into the public domain
+METADATA block #2
+  type: 9 (UNKNOWN)
+  is last: false
+  length: 3
+`
+	buf := new(bytes.Buffer)
+	if err := s.Dump(buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("invalid dump; expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestParseStreamFrames(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/172960.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ParseStream parses the audio frames, unlike Parse.
+	s, err := flac.ParseStream(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var samples uint64
+	for _, f := range s.Frames {
+		samples += uint64(len(f.SubFrames[0].Samples))
+	}
+	if samples != s.Info.SampleCount {
+		t.Errorf("invalid number of decoded samples; expected %d, got %d", s.Info.SampleCount, samples)
+	}
+	s, err = flac.Parse(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Frames != nil {
+		t.Errorf("unexpected audio frames parsed by Parse")
+	}
+}
@@ -0,0 +1,69 @@
+package frame
+
+// crc8Table is the lookup table of the CRC-8 used by frame headers, with the
+// polynomial x^8 + x^2 + x^1 + x^0 (0x07).
+var crc8Table = makeCRC8Table(0x07)
+
+// crc16Table is the lookup table of the CRC-16 used by frames, with the
+// polynomial x^16 + x^15 + x^2 + x^0 (0x8005).
+var crc16Table = makeCRC16Table(0x8005)
+
+// CRC8 returns the CRC-8 checksum of the provided data, as stored at the end of
+// each frame header. The checksum covers all bytes of the frame header,
+// starting with the sync code, except the CRC-8 itself.
+//
+// ref: http://flac.sourceforge.net/format.html#frame_header
+func CRC8(data []byte) uint8 {
+	var crc uint8
+	for _, b := range data {
+		crc = crc8Table[crc^b]
+	}
+	return crc
+}
+
+// CRC16 returns the CRC-16 checksum of the provided data, as stored at the end
+// of each frame. The checksum covers all bytes of the frame, starting with the
+// sync code, except the CRC-16 itself.
+//
+// ref: http://flac.sourceforge.net/format.html#frame_footer
+func CRC16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^b]
+	}
+	return crc
+}
+
+// makeCRC8Table returns the lookup table of a non-reflected CRC-8 with the
+// provided polynomial.
+func makeCRC8Table(poly uint8) (table [256]uint8) {
+	for i := range table {
+		crc := uint8(i)
+		for j := 0; j < 8; j++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ poly
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}
+
+// makeCRC16Table returns the lookup table of a non-reflected CRC-16 with the
+// provided polynomial.
+func makeCRC16Table(poly uint16) (table [256]uint16) {
+	for i := range table {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ poly
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}
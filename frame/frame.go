@@ -3,6 +3,7 @@ package frame
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"hash"
 	"io"
@@ -49,6 +50,10 @@ func NewFrame(r io.Reader, md5sum hash.Hash) (frame *Frame, err error) {
 	// Subframes.
 	br := bit.NewReader(hr)
 	hdr := frame.Header
+	if hdr.BitsPerSample == 0 {
+		// TODO(u): Get bits-per-sample from StreamInfo.
+		return nil, errors.New("frame.NewFrame: bits-per-sample from StreamInfo not yet supported")
+	}
 	for subFrameNum := 0; subFrameNum < hdr.ChannelOrder.ChannelCount(); subFrameNum++ {
 		// NOTE: This piece of code is based on https://github.com/eaburns/flac/blob/master/decode.go#L437
 		// It is governed by a MIT license: https://github.com/eaburns/flac/blob/master/LICENSE
@@ -0,0 +1,181 @@
+package frame
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mewkiz/flac/meta"
+)
+
+func TestDecodeBlockSize(t *testing.T) {
+	golden := []struct {
+		code        uint8
+		sampleCount uint16
+		ok          bool
+	}{
+		{code: 0, sampleCount: 0, ok: false},
+		{code: 1, sampleCount: 192, ok: true},
+		{code: 2, sampleCount: 576, ok: true},
+		{code: 3, sampleCount: 1152, ok: true},
+		{code: 4, sampleCount: 2304, ok: true},
+		{code: 5, sampleCount: 4608, ok: true},
+		{code: 6, sampleCount: 0, ok: false},
+		{code: 7, sampleCount: 0, ok: false},
+		{code: 8, sampleCount: 256, ok: true},
+		{code: 9, sampleCount: 512, ok: true},
+		{code: 10, sampleCount: 1024, ok: true},
+		{code: 11, sampleCount: 2048, ok: true},
+		{code: 12, sampleCount: 4096, ok: true},
+		{code: 13, sampleCount: 8192, ok: true},
+		{code: 14, sampleCount: 16384, ok: true},
+		{code: 15, sampleCount: 32768, ok: true},
+	}
+	for _, g := range golden {
+		sampleCount, ok := decodeBlockSize(g.code)
+		if sampleCount != g.sampleCount || ok != g.ok {
+			t.Errorf("code %04b: expected (%d, %t), got (%d, %t)", g.code, g.sampleCount, g.ok, sampleCount, ok)
+		}
+	}
+}
+
+func TestDecodeSampleRate(t *testing.T) {
+	si := &meta.StreamInfo{SampleRate: 11025}
+	golden := []struct {
+		code       uint8
+		si         *meta.StreamInfo
+		sampleRate uint32
+		fail       bool
+	}{
+		{code: 0, si: si, sampleRate: 11025},
+		{code: 0, si: nil, sampleRate: 0},
+		{code: 1, sampleRate: 88200},
+		{code: 2, sampleRate: 176400},
+		{code: 3, sampleRate: 192000},
+		{code: 4, sampleRate: 8000},
+		{code: 5, sampleRate: 16000},
+		{code: 6, sampleRate: 22050},
+		{code: 7, sampleRate: 24000},
+		{code: 8, sampleRate: 32000},
+		{code: 9, sampleRate: 44100},
+		{code: 10, sampleRate: 48000},
+		{code: 11, sampleRate: 96000},
+		{code: 12, sampleRate: 0},
+		{code: 13, sampleRate: 0},
+		{code: 14, sampleRate: 0},
+		{code: 15, fail: true},
+		{code: 16, fail: true},
+	}
+	for _, g := range golden {
+		sampleRate, err := decodeSampleRate(g.code, g.si)
+		if g.fail {
+			if err == nil {
+				t.Errorf("code %04b: expected error, got sample rate %d", g.code, sampleRate)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("code %04b: unexpected error; %v", g.code, err)
+			continue
+		}
+		if sampleRate != g.sampleRate {
+			t.Errorf("code %04b: expected sample rate %d, got %d", g.code, g.sampleRate, sampleRate)
+		}
+	}
+}
+
+func TestCRC8(t *testing.T) {
+	golden := []struct {
+		data []byte
+		want uint8
+	}{
+		{data: nil, want: 0x00},
+		{data: []byte("123456789"), want: 0xF4},
+		// Frame header of the first frame of testdata/59996.flac.
+		{data: []byte{0xFF, 0xF8, 0xC9, 0xAC, 0x00}, want: 0xD9},
+		{data: []byte{0xFF, 0xF8, 0xC9, 0xAC, 0x00, 0xD9}, want: 0x00},
+	}
+	for _, g := range golden {
+		got := CRC8(g.data)
+		if got != g.want {
+			t.Errorf("%q: expected 0x%02X, got 0x%02X", g.data, g.want, got)
+		}
+	}
+}
+
+func TestCRC16(t *testing.T) {
+	golden := []struct {
+		data []byte
+		want uint16
+	}{
+		{data: nil, want: 0x0000},
+		{data: []byte("123456789"), want: 0xFEE8},
+		// Data followed by its big-endian CRC-16 has a zero checksum.
+		{data: []byte("123456789\xFE\xE8"), want: 0x0000},
+	}
+	for _, g := range golden {
+		got := CRC16(g.data)
+		if got != g.want {
+			t.Errorf("%q: expected 0x%04X, got 0x%04X", g.data, g.want, got)
+		}
+	}
+}
+
+func TestReadUTF8Uint(t *testing.T) {
+	golden := []struct {
+		data []byte
+		n    uint64
+		size int
+	}{
+		{data: []byte{0x00}, n: 0, size: 1},
+		{data: []byte{0x7F}, n: 0x7F, size: 1},
+		{data: []byte{0xC2, 0x80}, n: 0x80, size: 2},
+		{data: []byte{0xDF, 0xBF}, n: 0x7FF, size: 2},
+		{data: []byte{0xE0, 0xA0, 0x80}, n: 0x800, size: 3},
+		{data: []byte{0xEF, 0xBF, 0xBF}, n: 0xFFFF, size: 3},
+		{data: []byte{0xF0, 0x90, 0x80, 0x80}, n: 0x10000, size: 4},
+		{data: []byte{0xF8, 0x88, 0x80, 0x80, 0x80}, n: 0x200000, size: 5},
+		{data: []byte{0xFC, 0x84, 0x80, 0x80, 0x80, 0x80}, n: 0x4000000, size: 6},
+		{data: []byte{0xFE, 0x82, 0x80, 0x80, 0x80, 0x80, 0x80}, n: 0x80000000, size: 7},
+		{data: []byte{0xFE, 0xBF, 0xBF, 0xBF, 0xBF, 0xBF, 0xBF}, n: 1<<36 - 1, size: 7},
+		// Trailing data is not read.
+		{data: []byte{0x05, 0xFF}, n: 5, size: 1},
+	}
+	for _, g := range golden {
+		r := bytes.NewReader(g.data)
+		n, size, err := ReadUTF8Uint(r)
+		if err != nil {
+			t.Errorf("% X: unexpected error; %v", g.data, err)
+			continue
+		}
+		if n != g.n || size != g.size {
+			t.Errorf("% X: expected (%d, %d), got (%d, %d)", g.data, g.n, g.size, n, size)
+		}
+		if read := len(g.data) - r.Len(); read != size {
+			t.Errorf("% X: expected %d bytes read, got %d", g.data, size, read)
+		}
+	}
+}
+
+func TestReadUTF8UintInvalid(t *testing.T) {
+	golden := [][]byte{
+		// Unexpected continuation byte.
+		{0x80},
+		// Invalid leading byte.
+		{0xFF},
+		// Expected continuation byte.
+		{0xC2, 0x41},
+		{0xE0, 0xA0, 0xC0},
+		// Larger representation than necessary.
+		{0xC1, 0xBF},
+		{0xFE, 0x81, 0xBF, 0xBF, 0xBF, 0xBF, 0xBF},
+		// Truncated.
+		{},
+		{0xE0, 0xA0},
+	}
+	for _, data := range golden {
+		n, size, err := ReadUTF8Uint(bytes.NewReader(data))
+		if err == nil {
+			t.Errorf("% X: expected error, got (%d, %d)", data, n, size)
+		}
+	}
+}
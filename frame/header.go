@@ -5,7 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"math"
+
+	"github.com/mewkiz/flac/meta"
 
 	"github.com/mewkiz/pkg/bit"
 	"github.com/mewkiz/pkg/dbg"
@@ -225,9 +226,8 @@ func NewHeader(r io.Reader) (hdr *Header, err error) {
 	switch n {
 	case 0:
 		// 000: get from STREAMINFO metadata block.
-		// TODO(u): Should we try to read StreamInfo from here? We won't always
-		// have access to it.
-		panic("not yet implemented; bits-per-sample 0")
+		// BitsPerSample is left as 0, since StreamInfo is not accessible from
+		// here.
 	case 1:
 		// 001: 8 bits per sample.
 		hdr.BitsPerSample = 8
@@ -261,14 +261,14 @@ func NewHeader(r io.Reader) (hdr *Header, err error) {
 	// "UTF-8" coded sample number or frame number.
 	if hdr.HasVariableSampleCount {
 		// Sample number.
-		hdr.SampleNum, err = decodeUTF8Int(hr)
+		hdr.SampleNum, _, err = ReadUTF8Uint(hr)
 		if err != nil {
 			return nil, err
 		}
 		dbg.Println("UTF-8 decoded sample number:", hdr.SampleNum)
 	} else {
 		// Frame number.
-		frameNum, err := decodeUTF8Int(hr)
+		frameNum, _, err := ReadUTF8Uint(hr)
 		if err != nil {
 			return nil, err
 		}
@@ -277,26 +277,10 @@ func NewHeader(r io.Reader) (hdr *Header, err error) {
 	}
 
 	// Block size.
-	//    0000: reserved.
-	//    0001: 192 samples.
-	//    0010-0101: 576 * (2^(n-2)) samples, i.e. 576/1152/2304/4608.
-	//    0110: get 8 bit (sampleCount-1) from end of header.
-	//    0111: get 16 bit (sampleCount-1) from end of header.
-	//    1000-1111: 256 * (2^(n-8)) samples, i.e. 256/512/1024/2048/4096/8192/
-	//               16384/32768.
 	// field 3: sample_count_spec (4 bits)
 	n = fields[3]
-	switch {
-	case n == 0:
-		// 0000: reserved.
-		return nil, errors.New("frame.NewHeader: invalid block size; reserved bit pattern")
-	case n == 1:
-		// 0001: 192 samples.
-		hdr.SampleCount = 192
-	case n >= 2 && n <= 5:
-		// 0010-0101: 576 * (2^(n-2)) samples, i.e. 576/1152/2304/4608.
-		hdr.SampleCount = uint16(576 * math.Pow(2, float64(n-2)))
-	case n == 6:
+	switch n {
+	case 6:
 		// 0110: get 8 bit (sampleCount-1) from end of header.
 		var x uint8
 		err = binary.Read(hr, binary.BigEndian, &x)
@@ -304,7 +288,7 @@ func NewHeader(r io.Reader) (hdr *Header, err error) {
 			return nil, err
 		}
 		hdr.SampleCount = uint16(x) + 1
-	case n == 7:
+	case 7:
 		// 0111: get 16 bit (sampleCount-1) from end of header.
 		var x uint16
 		err = binary.Read(hr, binary.BigEndian, &x)
@@ -312,72 +296,18 @@ func NewHeader(r io.Reader) (hdr *Header, err error) {
 			return nil, err
 		}
 		hdr.SampleCount = x + 1
-	case n >= 8 && n <= 15:
-		// 1000-1111: 256 * (2^(n-8)) samples, i.e. 256/512/1024/2048/4096/8192/
-		//            16384/32768.
-		hdr.SampleCount = uint16(256 * math.Pow(2, float64(n-8)))
 	default:
-		// should be unreachable.
-		panic(fmt.Errorf("frame.NewHeader: unhandled block size bit pattern: %04b", n))
+		sampleCount, ok := decodeBlockSize(uint8(n))
+		if !ok {
+			return nil, errors.New("frame.NewHeader: invalid block size; reserved bit pattern")
+		}
+		hdr.SampleCount = sampleCount
 	}
 
-	// Sample rate:
-	//    0000: get from STREAMINFO metadata block.
-	//    0001: 88.2kHz.
-	//    0010: 176.4kHz.
-	//    0011: 192kHz.
-	//    0100: 8kHz.
-	//    0101: 16kHz.
-	//    0110: 22.05kHz.
-	//    0111: 24kHz.
-	//    1000: 32kHz.
-	//    1001: 44.1kHz.
-	//    1010: 48kHz.
-	//    1011: 96kHz.
-	//    1100: get 8 bit sample rate (in kHz) from end of header.
-	//    1101: get 16 bit sample rate (in Hz) from end of header.
-	//    1110: get 16 bit sample rate (in tens of Hz) from end of header.
-	//    1111: invalid, to prevent sync-fooling string of 1s.
+	// Sample rate.
 	// field 4: sample_rate_spec (4 bits)
 	n = fields[4]
 	switch n {
-	case 0:
-		// 0000: get from STREAMINFO metadata block.
-		// TODO(u): Add flag to get from StreamInfo?
-		panic("not yet implemented; sample rate 0")
-	case 1:
-		//0001: 88.2kHz.
-		hdr.SampleRate = 88200
-	case 2:
-		//0010: 176.4kHz.
-		hdr.SampleRate = 176400
-	case 3:
-		//0011: 192kHz.
-		hdr.SampleRate = 192000
-	case 4:
-		//0100: 8kHz.
-		hdr.SampleRate = 8000
-	case 5:
-		//0101: 16kHz.
-		hdr.SampleRate = 16000
-	case 6:
-		//0110: 22.05kHz.
-		hdr.SampleRate = 22050
-	case 7:
-		//0111: 24kHz.
-		hdr.SampleRate = 24000
-	case 8:
-		//1000: 32kHz.
-		hdr.SampleRate = 32000
-	case 9:
-		//1001: 44.1kHz.
-		hdr.SampleRate = 44100
-	case 10:
-		//1010: 48kHz.
-		hdr.SampleRate = 48000
-	case 11:
-		//1011: 96kHz.
-		hdr.SampleRate = 96000
 	case 12:
 		//1100: get 8 bit sample rate (in kHz) from end of header.
 		var sampleRate_kHz uint8
@@ -402,12 +332,13 @@ func NewHeader(r io.Reader) (hdr *Header, err error) {
 			return nil, err
 		}
 		hdr.SampleRate = uint32(sampleRate_daHz) * 10
-	case 15:
-		//1111: invalid, to prevent sync-fooling string of 1s.
-		return nil, fmt.Errorf("frame.NewHeader: invalid sample rate bit pattern: %04b", n)
 	default:
-		// should be unreachable.
-		panic(fmt.Errorf("frame.NewHeader: unhandled sample rate bit pattern: %04b", n))
+		// The sample rate is left as 0 for 0000 (get from STREAMINFO metadata
+		// block), since StreamInfo is not accessible from here.
+		hdr.SampleRate, err = decodeSampleRate(uint8(n), nil)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Verify the CRC-8.
@@ -424,3 +355,101 @@ func NewHeader(r io.Reader) (hdr *Header, err error) {
 
 	return hdr, nil
 }
+
+// decodeBlockSize returns the block size in samples of the provided 4-bit
+// block size code of a frame header, and a boolean indicating if the block size
+// is defined by the code itself. It returns false for the reserved code 0000,
+// and for the codes 0110 and 0111, for which (sampleCount-1) is stored as an 8
+// or 16 bit number at the end of the frame header.
+//
+// Block size:
+//    0000: reserved.
+//    0001: 192 samples.
+//    0010-0101: 576 * (2^(n-2)) samples, i.e. 576/1152/2304/4608.
+//    0110: get 8 bit (sampleCount-1) from end of header.
+//    0111: get 16 bit (sampleCount-1) from end of header.
+//    1000-1111: 256 * (2^(n-8)) samples, i.e. 256/512/1024/2048/4096/8192/
+//               16384/32768.
+//
+// ref: http://flac.sourceforge.net/format.html#frame_header
+func decodeBlockSize(code uint8) (sampleCount uint16, ok bool) {
+	switch {
+	case code == 1:
+		// 0001: 192 samples.
+		return 192, true
+	case code >= 2 && code <= 5:
+		// 0010-0101: 576 * (2^(n-2)) samples, i.e. 576/1152/2304/4608.
+		return 576 << (code - 2), true
+	case code >= 8 && code <= 15:
+		// 1000-1111: 256 * (2^(n-8)) samples, i.e. 256/512/1024/2048/4096/8192/
+		//            16384/32768.
+		return 256 << (code - 8), true
+	}
+	// 0000: reserved.
+	// 0110: get 8 bit (sampleCount-1) from end of header.
+	// 0111: get 16 bit (sampleCount-1) from end of header.
+	return 0, false
+}
+
+// decodeSampleRate returns the sample rate in Hz of the provided 4-bit sample
+// rate code of a frame header. For the code 0000 the sample rate of streamInfo
+// is returned, or 0 if streamInfo is nil. For the codes 1100-1110 the sample
+// rate is stored at the end of the frame header, and 0 is returned.
+//
+// Sample rate:
+//    0000: get from STREAMINFO metadata block.
+//    0001: 88.2kHz.
+//    0010: 176.4kHz.
+//    0011: 192kHz.
+//    0100: 8kHz.
+//    0101: 16kHz.
+//    0110: 22.05kHz.
+//    0111: 24kHz.
+//    1000: 32kHz.
+//    1001: 44.1kHz.
+//    1010: 48kHz.
+//    1011: 96kHz.
+//    1100: get 8 bit sample rate (in kHz) from end of header.
+//    1101: get 16 bit sample rate (in Hz) from end of header.
+//    1110: get 16 bit sample rate (in tens of Hz) from end of header.
+//    1111: invalid, to prevent sync-fooling string of 1s.
+//
+// ref: http://flac.sourceforge.net/format.html#frame_header
+func decodeSampleRate(code uint8, streamInfo *meta.StreamInfo) (sampleRate uint32, err error) {
+	switch code {
+	case 0:
+		// 0000: get from STREAMINFO metadata block.
+		if streamInfo == nil {
+			return 0, nil
+		}
+		return streamInfo.SampleRate, nil
+	case 12, 13, 14:
+		// 1100: get 8 bit sample rate (in kHz) from end of header.
+		// 1101: get 16 bit sample rate (in Hz) from end of header.
+		// 1110: get 16 bit sample rate (in tens of Hz) from end of header.
+		return 0, nil
+	case 15:
+		// 1111: invalid, to prevent sync-fooling string of 1s.
+		return 0, fmt.Errorf("frame.decodeSampleRate: invalid sample rate bit pattern: %04b", code)
+	}
+	if int(code) >= len(sampleRates) {
+		return 0, fmt.Errorf("frame.decodeSampleRate: invalid sample rate code; expected <= 15, got %d", code)
+	}
+	return sampleRates[code], nil
+}
+
+// sampleRates maps from the sample rate codes 0001-1011 of a frame header to
+// their sample rates in Hz.
+var sampleRates = [...]uint32{
+	1:  88200,  // 0001: 88.2kHz.
+	2:  176400, // 0010: 176.4kHz.
+	3:  192000, // 0011: 192kHz.
+	4:  8000,   // 0100: 8kHz.
+	5:  16000,  // 0101: 16kHz.
+	6:  22050,  // 0110: 22.05kHz.
+	7:  24000,  // 0111: 24kHz.
+	8:  32000,  // 1000: 32kHz.
+	9:  44100,  // 1001: 44.1kHz.
+	10: 48000,  // 1010: 48kHz.
+	11: 96000,  // 1011: 96kHz.
+}
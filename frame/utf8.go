@@ -34,7 +34,9 @@ const (
 	rune6Max = 1<<31 - 1
 )
 
-// decodeUTF8Int decodes a "UTF-8" coded number and returns it.
+// ReadUTF8Uint reads and decodes a "UTF-8" coded number, as used for the frame
+// and sample numbers of frame headers. It returns the decoded number and the
+// number of bytes read, which is between 1 and 7.
 //
 // ref: http://permalink.gmane.org/gmane.comp.audio.compression.flac.devel/3033
 //
@@ -57,23 +59,23 @@ const (
 //         - if B does not match 10xxxxxx, the encoding is invalid
 //         - set R = R or <the lower 6 bits from B>
 //    - the read value is R
-func decodeUTF8Int(r io.Reader) (n uint64, err error) {
+func ReadUTF8Uint(r io.Reader) (n uint64, size int, err error) {
 	c0, err := readerutil.ReadByte(r)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
 	// 1-byte, 7-bit sequence?
 	if c0 < tx {
 		// if c0 == 0xxxxxxx
 		// total: 7 bits (7)
-		return uint64(c0), nil
+		return uint64(c0), 1, nil
 	}
 
 	// unexpected continuation byte?
 	if c0 < t2 {
 		// if c0 == 10xxxxxx
-		return 0, errors.New("frame.decodeUTF8Int: unexpected continuation byte")
+		return 0, 0, errors.New("frame.ReadUTF8Uint: unexpected continuation byte")
 	}
 
 	// get number of continuation bytes and store bits from c0.
@@ -109,6 +111,9 @@ func decodeUTF8Int(r io.Reader) (n uint64, err error) {
 		// total: 36 bits (0 + 6 + 6 + 6 + 6 + 6 + 6)
 		l = 6
 		n = 0
+	default:
+		// if c0 == 11111111
+		return 0, 0, errors.New("frame.ReadUTF8Uint: invalid leading byte 0xFF")
 	}
 
 	// store bits from continuation bytes.
@@ -116,11 +121,11 @@ func decodeUTF8Int(r io.Reader) (n uint64, err error) {
 		n <<= 6
 		c, err := readerutil.ReadByte(r)
 		if err != nil {
-			return 0, err
+			return 0, 0, err
 		}
 		if c < tx || t2 <= c {
 			// if c != 10xxxxxx
-			return 0, errors.New("frame.decodeUTF8Int: expected continuation byte")
+			return 0, 0, errors.New("frame.ReadUTF8Uint: expected continuation byte")
 		}
 		n |= uint64(c & maskx)
 	}
@@ -129,29 +134,29 @@ func decodeUTF8Int(r io.Reader) (n uint64, err error) {
 	switch l {
 	case 1:
 		if n <= rune1Max {
-			return 0, fmt.Errorf("frame.decodeUTF8Int: larger number representation than necessary; n (%d) stored in %d bytes, could be stored in %d bytes", n, l+1, l)
+			return 0, 0, fmt.Errorf("frame.ReadUTF8Uint: larger number representation than necessary; n (%d) stored in %d bytes, could be stored in %d bytes", n, l+1, l)
 		}
 	case 2:
 		if n <= rune2Max {
-			return 0, fmt.Errorf("frame.decodeUTF8Int: larger number representation than necessary; n (%d) stored in %d bytes, could be stored in %d bytes", n, l+1, l)
+			return 0, 0, fmt.Errorf("frame.ReadUTF8Uint: larger number representation than necessary; n (%d) stored in %d bytes, could be stored in %d bytes", n, l+1, l)
 		}
 	case 3:
 		if n <= rune3Max {
-			return 0, fmt.Errorf("frame.decodeUTF8Int: larger number representation than necessary; n (%d) stored in %d bytes, could be stored in %d bytes", n, l+1, l)
+			return 0, 0, fmt.Errorf("frame.ReadUTF8Uint: larger number representation than necessary; n (%d) stored in %d bytes, could be stored in %d bytes", n, l+1, l)
 		}
 	case 4:
 		if n <= rune4Max {
-			return 0, fmt.Errorf("frame.decodeUTF8Int: larger number representation than necessary; n (%d) stored in %d bytes, could be stored in %d bytes", n, l+1, l)
+			return 0, 0, fmt.Errorf("frame.ReadUTF8Uint: larger number representation than necessary; n (%d) stored in %d bytes, could be stored in %d bytes", n, l+1, l)
 		}
 	case 5:
 		if n <= rune5Max {
-			return 0, fmt.Errorf("frame.decodeUTF8Int: larger number representation than necessary; n (%d) stored in %d bytes, could be stored in %d bytes", n, l+1, l)
+			return 0, 0, fmt.Errorf("frame.ReadUTF8Uint: larger number representation than necessary; n (%d) stored in %d bytes, could be stored in %d bytes", n, l+1, l)
 		}
 	case 6:
 		if n <= rune6Max {
-			return 0, fmt.Errorf("frame.decodeUTF8Int: larger number representation than necessary; n (%d) stored in %d bytes, could be stored in %d bytes", n, l+1, l)
+			return 0, 0, fmt.Errorf("frame.ReadUTF8Uint: larger number representation than necessary; n (%d) stored in %d bytes, could be stored in %d bytes", n, l+1, l)
 		}
 	}
 
-	return n, nil
+	return n, l + 1, nil
 }
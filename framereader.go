@@ -0,0 +1,260 @@
+package flac
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+// maxHeaderSize is the maximum size in bytes of a frame header; 4 bytes of
+// fixed fields, up to 7 bytes of "UTF-8" coded sample number, up to 2 bytes of
+// sample count, up to 2 bytes of sample rate and 1 byte of CRC-8.
+const maxHeaderSize = 16
+
+// A FrameReader enumerates the audio frames of a stream by parsing their frame
+// headers, without decoding the subframes.
+type FrameReader struct {
+	// The underlying reader of the audio frames.
+	br *bufio.Reader
+	// The previously parsed frame header, or nil before the first frame.
+	prev *frame.Header
+	// Number of bytes consumed from the underlying reader.
+	n int64
+	// Byte offset of the previously parsed frame header, relative to the
+	// start of the frame reader.
+	offset int64
+}
+
+// NewFrameReader returns a reader of the audio frames of the stream. The
+// metadata blocks must have been parsed, leaving the stream positioned at the
+// first audio frame. The stream reader is buffered by the frame reader, so the
+// stream should not be read directly once the frame reader is in use.
+func (s *Stream) NewFrameReader() (*FrameReader, error) {
+	if s.Info == nil {
+		return nil, errors.New("flac.Stream.NewFrameReader: metadata blocks not parsed")
+	}
+	fr := &FrameReader{br: bufio.NewReader(s.r)}
+	return fr, nil
+}
+
+// Next parses and returns the header of the next audio frame. The frame header
+// is located by scanning for the frame sync code, and is verified by its CRC-8
+// checksum and by the continuity of its frame or sample number with the
+// previous frame. Next returns io.EOF when there are no more audio frames.
+func (fr *FrameReader) Next() (hdr *frame.Header, err error) {
+	for {
+		buf, err := fr.br.Peek(maxHeaderSize)
+		if len(buf) < 2 {
+			if err == nil || err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		// Sync code (14 bits), reserved (1 bit) and blocking strategy (1 bit):
+		//    11111111 1111100x
+		if buf[0] != 0xFF || buf[1]&0xFE != 0xF8 {
+			// Skip to the next candidate sync code.
+			n := len(buf)
+			if i := bytes.IndexByte(buf[1:], 0xFF); i != -1 {
+				n = i + 1
+			}
+			err = fr.discard(n)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		r := bytes.NewReader(buf)
+		hdr, err = frame.NewHeader(r)
+		if err != nil || !fr.follows(hdr) {
+			// False sync code; skip it.
+			err = fr.discard(1)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		fr.offset = fr.n
+		err = fr.discard(len(buf) - r.Len())
+		if err != nil {
+			return nil, err
+		}
+		fr.prev = hdr
+		return hdr, nil
+	}
+}
+
+// Offset returns the byte offset of the frame header most recently returned by
+// Next, relative to the position of the stream when the frame reader was
+// created; i.e. relative to the first audio frame for frame readers created
+// directly after parsing the metadata blocks.
+func (fr *FrameReader) Offset() int64 {
+	return fr.offset
+}
+
+// discard skips the next n bytes of the underlying reader.
+func (fr *FrameReader) discard(n int) error {
+	m, err := fr.br.Discard(n)
+	fr.n += int64(m)
+	return err
+}
+
+// follows returns true if the provided frame header directly follows the
+// previously parsed frame header, and false otherwise.
+func (fr *FrameReader) follows(hdr *frame.Header) bool {
+	prev := fr.prev
+	if prev == nil {
+		return true
+	}
+	if hdr.HasVariableSampleCount != prev.HasVariableSampleCount {
+		return false
+	}
+	if hdr.HasVariableSampleCount {
+		return hdr.SampleNum == prev.SampleNum+uint64(prev.SampleCount)
+	}
+	return hdr.FrameNum == prev.FrameNum+1
+}
+
+// EstimateDuration returns the duration of the stream. If the total number of
+// samples is not stored in the StreamInfo metadata block, as is the case for
+// streams written by some streaming encoders, the number of samples is
+// determined by scanning the frame headers of every audio frame, starting at
+// the first audio frame. The metadata blocks must have been parsed from the
+// start of the stream, and the underlying reader must be an io.Seeker for the
+// frame headers to be scanned; the position of the underlying reader is
+// restored afterwards.
+func (s *Stream) EstimateDuration() (d time.Duration, err error) {
+	if s.Info == nil {
+		return 0, errors.New("flac.Stream.EstimateDuration: metadata blocks not parsed")
+	}
+	if s.Info.SampleCount != 0 {
+		return s.Info.Duration(), nil
+	}
+	if s.Info.SampleRate == 0 {
+		return 0, errors.New("flac.Stream.EstimateDuration: invalid sample rate; expected > 0, got 0")
+	}
+	rs, ok := s.r.(io.Seeker)
+	if !ok {
+		return 0, errors.New("flac.Stream.EstimateDuration: unable to scan audio frames; underlying reader is not an io.Seeker")
+	}
+	cur, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_, serr := rs.Seek(cur, io.SeekStart)
+		if err == nil && serr != nil {
+			d, err = 0, serr
+		}
+	}()
+	_, err = rs.Seek(s.audioOffset, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+
+	// Sum the sample counts of the audio frames.
+	fr, err := s.NewFrameReader()
+	if err != nil {
+		return 0, err
+	}
+	var samples uint64
+	for {
+		hdr, err := fr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		samples += uint64(hdr.SampleCount)
+	}
+
+	si := *s.Info
+	si.SampleCount = samples
+	return si.Duration(), nil
+}
+
+// SeekOffset locates the audio frame containing the provided sample number. It
+// returns the byte offset of the frame, relative to the first audio frame as
+// returned by Stream.AudioOffset, and the sample number of the first sample of
+// the frame. Decoding from the returned offset and discarding sample-firstSample
+// samples yields sample-accurate seeking.
+//
+// The seek point of the SeekTable metadata block, if parsed, closest to but not
+// exceeding the sample is used as a starting point, from which the frame
+// headers are scanned up to the target frame. Without a seek table, the frame
+// headers are scanned from the first audio frame. The metadata blocks must have
+// been parsed from the start of the stream, and the underlying reader must be
+// an io.Seeker; the position of the underlying reader is restored afterwards.
+func (s *Stream) SeekOffset(sample uint64) (byteOffset int64, firstSample uint64, err error) {
+	if s.Info == nil {
+		return 0, 0, errors.New("flac.Stream.SeekOffset: metadata blocks not parsed")
+	}
+	if s.Info.SampleCount != 0 && sample >= s.Info.SampleCount {
+		return 0, 0, fmt.Errorf("flac.Stream.SeekOffset: invalid sample number; expected < %d, got %d", s.Info.SampleCount, sample)
+	}
+	rs, ok := s.r.(io.Seeker)
+	if !ok {
+		return 0, 0, errors.New("flac.Stream.SeekOffset: unable to scan audio frames; underlying reader is not an io.Seeker")
+	}
+	cur, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Locate the closest preceding seek point.
+	var start meta.SeekPoint
+	if block, ok := s.FindBlock(meta.TypeSeekTable); ok {
+		if st, ok := block.Body.(*meta.SeekTable); ok {
+			if point, ok := st.Search(sample); ok {
+				start = point
+			}
+		}
+	}
+	_, err = rs.Seek(s.audioOffset+int64(start.Offset), io.SeekStart)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Scan the frame headers up to the frame containing the sample.
+	fr, err := s.NewFrameReader()
+	if err != nil {
+		return 0, 0, err
+	}
+	firstSample = start.SampleNum
+	for {
+		hdr, err := fr.Next()
+		if err == io.EOF {
+			err = fmt.Errorf("flac.Stream.SeekOffset: sample number %d not found; stream ends at sample %d", sample, firstSample)
+		}
+		if err != nil {
+			rs.Seek(cur, io.SeekStart)
+			return 0, 0, err
+		}
+		if hdr.HasVariableSampleCount {
+			firstSample = hdr.SampleNum
+		}
+		if sample < firstSample+uint64(hdr.SampleCount) {
+			byteOffset = int64(start.Offset) + fr.Offset()
+			break
+		}
+		firstSample += uint64(hdr.SampleCount)
+	}
+
+	_, err = rs.Seek(cur, io.SeekStart)
+	if err != nil {
+		return 0, 0, err
+	}
+	return byteOffset, firstSample, nil
+}
@@ -0,0 +1,19 @@
+package flac
+
+import (
+	"encoding/json"
+
+	"github.com/mewkiz/flac/meta"
+)
+
+// MarshalJSON returns the JSON encoding of the metadata blocks of the stream.
+// Binary data, such as picture data and the bodies of reserved blocks, is
+// encoded as base64 strings. The audio frames are not included.
+func (s *Stream) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Blocks []*meta.Block
+	}{
+		Blocks: s.Blocks,
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,95 @@
+package flac
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mewkiz/flac/meta"
+)
+
+// A Warning describes a non-fatal problem encountered by ParseLenient.
+type Warning struct {
+	// Index of the metadata block in Stream.Blocks, or -1 for problems of the
+	// stream as a whole.
+	Block int
+	// Type of the metadata block.
+	Type meta.BlockType
+	// Description of the problem.
+	Msg string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("block %d (%v): %s", w.Block, w.Type, w.Msg)
+}
+
+// ParseLenient reads from the provided io.Reader and returns a FLAC bitstream
+// with all metadata blocks parsed, including the bodies of padding blocks.
+// Unlike Parse, non-fatal problems are collected as warnings instead of
+// failing; e.g. reserved block types, padding containing non-zero bytes,
+// invalid or duplicate Vorbis comment field names and a misplaced StreamInfo
+// block. The first StreamInfo block is used as Stream.Info, which is nil if the
+// stream contains none. The reader is left positioned at the first audio frame.
+func ParseLenient(r io.Reader) (s *Stream, warnings []Warning, err error) {
+	s, err = NewStream(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Read metadata blocks.
+	for i := 0; ; i++ {
+		block, err := meta.NewBlock(s.r)
+		if err != nil {
+			return nil, warnings, err
+		}
+		warn := func(msg string) {
+			warnings = append(warnings, Warning{Block: i, Type: block.Header.BlockType, Msg: msg})
+		}
+		err = block.ParseOptions(&meta.Options{Warn: warn})
+		if err != nil {
+			return nil, warnings, err
+		}
+		s.audioOffset += 4 + int64(block.Header.Length)
+		s.Blocks = append(s.Blocks, block)
+
+		switch body := block.Body.(type) {
+		case *meta.StreamInfo:
+			if s.Info == nil {
+				s.Info = body
+				if i != 0 {
+					warn("StreamInfo block is not the first block")
+				}
+			} else {
+				warn("duplicate StreamInfo block")
+			}
+		case *meta.VorbisComment:
+			seen := make(map[string]bool)
+			for _, entry := range body.Entries {
+				if entry.NoSeparator {
+					warn(fmt.Sprintf("Vorbis comment vector %q lacks a '=' separator", entry.Name))
+					continue
+				}
+				if !meta.ValidFieldName(entry.Name) {
+					warn(fmt.Sprintf("invalid Vorbis comment field name %q", entry.Name))
+				}
+				name := strings.ToUpper(entry.Name)
+				if seen[name] {
+					warn(fmt.Sprintf("duplicate Vorbis comment field name %q", entry.Name))
+				}
+				seen[name] = true
+			}
+		}
+		if block.Header.BlockType == meta.TypeReserved {
+			warn(fmt.Sprintf("reserved block type number %d", block.Header.RawType))
+		}
+
+		if block.Header.IsLast {
+			break
+		}
+	}
+	if s.Info == nil {
+		warnings = append(warnings, Warning{Block: -1, Msg: "missing StreamInfo block"})
+	}
+
+	return s, warnings, nil
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 )
 
 // registeredApplications maps from a registered application ID to a
@@ -55,10 +54,52 @@ func (id ID) String() string {
 type Application struct {
 	// Registered application ID.
 	ID ID
-	// Application data.
+	// Application data, as defined by the registered application.
 	Data []byte
 }
 
+// Name returns the name of the registered application which wrote the
+// Application metadata block, or the hexadecimal representation of the
+// application ID (e.g. "0x12345678") if the ID is not registered.
+func (app *Application) Name() string {
+	if s, ok := registeredApplications[app.ID]; ok {
+		return s
+	}
+	return hexID(app.ID)
+}
+
+// IDString returns the application ID as a string (e.g. "riff"), or its
+// hexadecimal representation if the ID contains non-printable characters.
+func (app *Application) IDString() string {
+	for i := 0; i < len(app.ID); i++ {
+		if app.ID[i] < 0x20 || app.ID[i] > 0x7E {
+			return hexID(app.ID)
+		}
+	}
+	return string(app.ID)
+}
+
+// hexID returns the hexadecimal representation of the provided application ID.
+func hexID(id ID) string {
+	return fmt.Sprintf("0x%X", string(id))
+}
+
+// NewApplication returns a new Application metadata block with the provided
+// application ID and data. The data is stored verbatim after the application
+// ID when the block is written, and is not copied.
+func NewApplication(id [4]byte, data []byte) *Block {
+	app := &Application{
+		ID:   ID(id[:]),
+		Data: data,
+	}
+	h := &BlockHeader{
+		BlockType: TypeApplication,
+		RawType:   rawBlockType[TypeApplication],
+		Length:    4 + len(data),
+	}
+	return &Block{Header: h, Body: app}
+}
+
 // ParseApplication parses and returns a new Application metadata block. The
 // provided io.Reader should limit the amount of data that can be read to
 // header.Length bytes.
@@ -72,15 +113,15 @@ type Application struct {
 //
 // ref: http://flac.sourceforge.net/format.html#metadata_block_application
 func ParseApplication(r io.Reader) (app *Application, err error) {
+	sc := getScratch()
+	defer sc.release()
+
 	// Application ID (size: 4 bytes).
-	buf, err := readBytes(r, 4)
+	buf, err := sc.readBytes(r, 4)
 	if err != nil {
 		return nil, err
 	}
 	app = &Application{ID: ID(buf)}
-	if _, ok := registeredApplications[app.ID]; !ok {
-		log.Printf("meta.ParseApplication: unregistered application ID %q.\n", string(app.ID))
-	}
 
 	// Data.
 	app.Data, err = ioutil.ReadAll(r)
@@ -90,3 +131,15 @@ func ParseApplication(r io.Reader) (app *Application, err error) {
 
 	return app, nil
 }
+
+// Marshal returns the binary representation of the Application metadata block
+// body, as specified by the application format described in ParseApplication.
+func (app *Application) Marshal() (buf []byte, err error) {
+	if len(app.ID) != 4 {
+		return nil, fmt.Errorf("meta.Application.Marshal: invalid application ID length; expected 4, got %d", len(app.ID))
+	}
+	buf = make([]byte, 0, 4+len(app.Data))
+	buf = append(buf, app.ID...)
+	buf = append(buf, app.Data...)
+	return buf, nil
+}
@@ -0,0 +1,73 @@
+package meta
+
+// Clone returns a deep copy of the metadata block, including its header and
+// body. The slices of the block body, such as the data of pictures and the
+// entries of Vorbis comments, are copied, so that the clone may be modified
+// without affecting the original block. Bodies produced by parsers registered
+// with RegisterBlockParser are not copied, as their contents are unknown.
+//
+// The clone shares the underlying reader of the original block.
+func (block *Block) Clone() *Block {
+	if block == nil {
+		return nil
+	}
+	clone := *block
+	if block.Header != nil {
+		hdr := *block.Header
+		clone.Header = &hdr
+	}
+	clone.Body = cloneBody(block.Body)
+	return &clone
+}
+
+// cloneBody returns a deep copy of the provided metadata block body.
+func cloneBody(body interface{}) interface{} {
+	switch body := body.(type) {
+	case *StreamInfo:
+		si := *body
+		return &si
+	case *Application:
+		app := *body
+		app.Data = cloneBytes(body.Data)
+		return &app
+	case *SeekTable:
+		st := *body
+		if body.Points != nil {
+			st.Points = append([]SeekPoint(nil), body.Points...)
+		}
+		return &st
+	case *VorbisComment:
+		vc := *body
+		if body.Entries != nil {
+			vc.Entries = append([]VorbisEntry(nil), body.Entries...)
+		}
+		return &vc
+	case *CueSheet:
+		cs := *body
+		if body.Tracks != nil {
+			cs.Tracks = make([]CueSheetTrack, len(body.Tracks))
+			for i, track := range body.Tracks {
+				if track.TrackIndexes != nil {
+					track.TrackIndexes = append([]CueSheetTrackIndex(nil), track.TrackIndexes...)
+				}
+				cs.Tracks[i] = track
+			}
+		}
+		return &cs
+	case *Picture:
+		pic := *body
+		pic.Data = cloneBytes(body.Data)
+		return &pic
+	case []byte:
+		return cloneBytes(body)
+	}
+	return body
+}
+
+// cloneBytes returns a copy of the provided byte slice, preserving nil.
+func cloneBytes(buf []byte) []byte {
+	if buf == nil {
+		return nil
+	}
+	return append([]byte{}, buf...)
+}
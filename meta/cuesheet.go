@@ -1,11 +1,14 @@
 package meta
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 
 	"github.com/eaburns/bit"
 )
@@ -64,7 +67,8 @@ type CueSheetTrack struct {
 	// within a CueSheet.
 	TrackNum uint8
 	// Track ISRC. This is a 12-digit alphanumeric code. A value of 12 ASCII NUL
-	// characters may be used to denote absence of an ISRC.
+	// characters may be used to denote absence of an ISRC. Trailing NUL
+	// characters are trimmed by ParseCueSheet, so an absent ISRC is empty.
 	ISRC string
 	// The track type: true for audio, false for non-audio.
 	IsAudio bool
@@ -93,6 +97,163 @@ type CueSheetTrackIndex struct {
 	IndexPointNum uint8
 }
 
+// IsCD reports whether the cue sheet corresponds to a Compact Disc; i.e. the
+// is_compact_disc flag, stored in the most significant bit of the byte
+// following the lead-in sample count. The remaining 7 bits of the byte are
+// reserved. It is equivalent to cs.IsCompactDisc.
+//
+// Note that the media catalog number, trimmed of trailing NUL characters, is
+// available as cs.MCN; a method of the same name cannot be provided, as it
+// would conflict with the field.
+func (cs *CueSheet) IsCD() bool {
+	return cs.IsCompactDisc
+}
+
+// LeadInSamples returns the number of lead-in samples of the cue sheet, or 0
+// if the cue sheet does not correspond to a Compact Disc, as the lead-in only
+// has meaning for CD-DA cue sheets.
+func (cs *CueSheet) LeadInSamples() uint64 {
+	if !cs.IsCompactDisc {
+		return 0
+	}
+	return cs.LeadInSampleCount
+}
+
+// Track returns the track with the provided track number, and a boolean
+// indicating if such a track was present. The returned track refers to the
+// track of the cue sheet, and may be used to modify it.
+func (cs *CueSheet) Track(number uint8) (track *CueSheetTrack, ok bool) {
+	for i := range cs.Tracks {
+		if cs.Tracks[i].TrackNum == number {
+			return &cs.Tracks[i], true
+		}
+	}
+	return nil, false
+}
+
+// ValidISRC returns true if the ISRC of the track is either absent or well
+// formed, and false otherwise. A well formed ISRC consists of 12 characters; a
+// 2 letter country code, a 3 character alphanumeric registrant code, a 2 digit
+// year of reference and a 5 digit designation code. An ISRC which is empty or
+// consists only of NUL characters denotes absence of an ISRC.
+//
+// ref: https://isrc.ifpi.org/en/isrc-standard/code-syntax
+func (track *CueSheetTrack) ValidISRC() bool {
+	isrc := track.ISRC
+	if strings.Trim(isrc, "\x00") == "" {
+		return true
+	}
+	if len(isrc) != 12 {
+		return false
+	}
+	for i := 0; i < len(isrc); i++ {
+		c := isrc[i]
+		isUpper := c >= 'A' && c <= 'Z'
+		isDigit := c >= '0' && c <= '9'
+		switch {
+		case i < 2:
+			// Country code.
+			if !isUpper {
+				return false
+			}
+		case i < 5:
+			// Registrant code.
+			if !isUpper && !isDigit {
+				return false
+			}
+		default:
+			// Year of reference and designation code.
+			if !isDigit {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Index returns the track index point with the provided index point number,
+// and a boolean indicating if such an index point was present. The returned
+// index point refers to the index point of the track, and may be used to
+// modify it.
+func (track *CueSheetTrack) Index(number uint8) (index *CueSheetTrackIndex, ok bool) {
+	for i := range track.TrackIndexes {
+		if track.TrackIndexes[i].IndexPointNum == number {
+			return &track.TrackIndexes[i], true
+		}
+	}
+	return nil, false
+}
+
+// StartSample returns the offset in samples of the INDEX 01 index point of the
+// track, relative to the beginning of the FLAC audio stream, and a boolean
+// indicating if the track contains an INDEX 01 index point. This is the start
+// of the track proper, excluding any pre-gap; i.e. the offset used for the
+// track by CD-DA, and by splitters which append the pre-gap to the previous
+// track.
+func (track *CueSheetTrack) StartSample() (offset uint64, ok bool) {
+	index, ok := track.Index(1)
+	if !ok {
+		return 0, false
+	}
+	return track.Offset + index.Offset, true
+}
+
+// PregapSamples returns the length in samples of the pre-gap of the track;
+// i.e. the distance from the INDEX 00 index point to the INDEX 01 index point.
+// It returns 0 if the track lacks either index point.
+func (track *CueSheetTrack) PregapSamples() uint64 {
+	pregap, ok := track.Index(0)
+	if !ok {
+		return 0
+	}
+	start, ok := track.Index(1)
+	if !ok || start.Offset < pregap.Offset {
+		return 0
+	}
+	return start.Offset - pregap.Offset
+}
+
+// A SampleRange specifies the samples of a track, as returned by
+// CueSheet.TrackRanges.
+type SampleRange struct {
+	// Offset of the first sample of the track, relative to the beginning of
+	// the FLAC audio stream.
+	Start uint64
+	// Offset of the sample following the last sample of the track; i.e. the
+	// range is half-open, and End-Start is the number of samples of the track.
+	End uint64
+	// Track number.
+	TrackNumber uint8
+}
+
+// TrackRanges returns the sample ranges of the tracks of the cue sheet,
+// excluding the lead-out track, for splitting the FLAC audio stream into
+// individual tracks. Each track ends where the next track begins, and the last
+// track ends at the offset of the lead-out track. The provided total number of
+// samples of the stream is used as the end of the last track if the cue sheet
+// lacks a lead-out track; i.e. if its last track contains index points.
+//
+// A track starts at its track offset, which includes any pre-gap. Use
+// CueSheetTrack.StartSample to exclude the pre-gap.
+func (cs *CueSheet) TrackRanges(totalSamples uint64) []SampleRange {
+	tracks := cs.Tracks
+	end := totalSamples
+	if n := len(tracks); n > 0 && len(tracks[n-1].TrackIndexes) == 0 {
+		// Lead-out track.
+		end = tracks[n-1].Offset
+		tracks = tracks[:n-1]
+	}
+	var ranges []SampleRange
+	for i, track := range tracks {
+		r := SampleRange{Start: track.Offset, End: end, TrackNumber: track.TrackNum}
+		if i+1 < len(tracks) {
+			r.End = tracks[i+1].Offset
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
 // ParseCueSheet parses and returns a new CueSheet metadata block. The provided
 // io.Reader should limit the amount of data that can be read to header.Length
 // bytes.
@@ -129,10 +290,13 @@ type CueSheetTrackIndex struct {
 //
 // ref: http://flac.sourceforge.net/format.html#metadata_block_cuesheet
 func ParseCueSheet(r io.Reader) (cs *CueSheet, err error) {
+	sc := getScratch()
+	defer sc.release()
+
 	errReservedNotZero := errors.New("meta.ParseCueSheet: all reserved bits must be 0")
 
 	// Media catalog number (size: 128 bytes).
-	buf, err := readBytes(r, 128)
+	buf, err := sc.readBytes(r, 128)
 	if err != nil {
 		return nil, err
 	}
@@ -167,7 +331,7 @@ func ParseCueSheet(r io.Reader) (cs *CueSheet, err error) {
 	if fields[1] != 0 {
 		return nil, errReservedNotZero
 	}
-	buf, err = readBytes(r, 258) // 258 reserved bytes.
+	buf, err = sc.readBytes(r, 258) // 258 reserved bytes.
 	if err != nil {
 		return nil, err
 	}
@@ -233,7 +397,7 @@ func ParseCueSheet(r io.Reader) (cs *CueSheet, err error) {
 		}
 
 		// Track ISRC (size: 12 bytes).
-		buf, err = readBytes(r, 12)
+		buf, err = sc.readBytes(r, 12)
 		if err != nil {
 			return nil, err
 		}
@@ -264,7 +428,7 @@ func ParseCueSheet(r io.Reader) (cs *CueSheet, err error) {
 		if fields[2] != 0 {
 			return nil, errReservedNotZero
 		}
-		buf, err = readBytes(r, 13) // 13 reserved bytes.
+		buf, err = sc.readBytes(r, 13) // 13 reserved bytes.
 		if err != nil {
 			return nil, err
 		}
@@ -311,7 +475,7 @@ func ParseCueSheet(r io.Reader) (cs *CueSheet, err error) {
 				}
 
 				// Reserved.
-				buf, err = readBytes(r, 3) // 3 reserved bytes.
+				buf, err = sc.readBytes(r, 3) // 3 reserved bytes.
 				if err != nil {
 					return nil, err
 				}
@@ -325,6 +489,319 @@ func ParseCueSheet(r io.Reader) (cs *CueSheet, err error) {
 	return cs, nil
 }
 
+// Marshal returns the binary representation of the CueSheet metadata block
+// body, as specified by the cue sheet format described in ParseCueSheet. An
+// error is returned if the cue sheet violates the rules of the specification;
+// e.g. if the last track is not a lead-out track, or if the track offsets of a
+// CD-DA cue sheet are not evenly divisible by 588 samples.
+func (cs *CueSheet) Marshal() (buf []byte, err error) {
+	err = cs.verify()
+	if err != nil {
+		return nil, fmt.Errorf("meta.CueSheet.Marshal: %w", err)
+	}
+
+	// Media catalog number (size: 128 bytes).
+	if len(cs.MCN) > 128 {
+		return nil, fmt.Errorf("meta.CueSheet.Marshal: invalid media catalog number length; expected <= 128, got %d", len(cs.MCN))
+	}
+	buf = append(buf, cs.MCN...)
+	buf = append(buf, make([]byte, 128-len(cs.MCN))...)
+
+	// Lead-in sample count.
+	buf = binary.BigEndian.AppendUint64(buf, cs.LeadInSampleCount)
+
+	// Is compact disc, followed by 7 reserved bits and 258 reserved bytes.
+	var flags uint8
+	if cs.IsCompactDisc {
+		flags = 0x80
+	}
+	buf = append(buf, flags)
+	buf = append(buf, make([]byte, 258)...)
+
+	// Track count.
+	if len(cs.Tracks) > 255 {
+		return nil, fmt.Errorf("meta.CueSheet.Marshal: too many tracks; expected <= 255, got %d", len(cs.Tracks))
+	}
+	buf = append(buf, uint8(len(cs.Tracks)))
+
+	// Tracks.
+	for _, track := range cs.Tracks {
+		// Track offset and track number.
+		buf = binary.BigEndian.AppendUint64(buf, track.Offset)
+		buf = append(buf, track.TrackNum)
+
+		// Track ISRC (size: 12 bytes).
+		if len(track.ISRC) > 12 {
+			return nil, fmt.Errorf("meta.CueSheet.Marshal: invalid ISRC length; expected <= 12, got %d", len(track.ISRC))
+		}
+		buf = append(buf, track.ISRC...)
+		buf = append(buf, make([]byte, 12-len(track.ISRC))...)
+
+		// Is audio and has pre-emphasis, followed by 6 reserved bits and 13
+		// reserved bytes.
+		flags = 0
+		if !track.IsAudio {
+			flags |= 0x80
+		}
+		if track.HasPreEmphasis {
+			flags |= 0x40
+		}
+		buf = append(buf, flags)
+		buf = append(buf, make([]byte, 13)...)
+
+		// Track index point count.
+		if len(track.TrackIndexes) > 255 {
+			return nil, fmt.Errorf("meta.CueSheet.Marshal: too many track index points; expected <= 255, got %d", len(track.TrackIndexes))
+		}
+		buf = append(buf, uint8(len(track.TrackIndexes)))
+
+		// Track indexes, each followed by 3 reserved bytes.
+		for _, trackIndex := range track.TrackIndexes {
+			buf = binary.BigEndian.AppendUint64(buf, trackIndex.Offset)
+			buf = append(buf, trackIndex.IndexPointNum, 0, 0, 0)
+		}
+	}
+
+	return buf, nil
+}
+
+// WriteCue writes the cue sheet to w in the textual .cue file format, referring
+// to audioFilename as the audio file. The provided sample rate (e.g. as given
+// by the StreamInfo metadata block) is used to convert the sample offsets into
+// MM:SS:FF timecodes, where FF is the number of CD frames (1/75th of a second).
+// No TRACK line is written for the lead-out track. The audio filename is written
+// verbatim within double quotes, as .cue files have no escape sequences; an
+// error is returned if it contains a double quote or a line break.
+func (cs *CueSheet) WriteCue(w io.Writer, audioFilename string, sampleRate uint32) (err error) {
+	if sampleRate == 0 {
+		return errors.New("meta.CueSheet.WriteCue: invalid sample rate; expected > 0, got 0")
+	}
+	if strings.ContainsAny(audioFilename, "\"\r\n") {
+		return fmt.Errorf("meta.CueSheet.WriteCue: invalid audio filename %q; must not contain double quotes or line breaks", audioFilename)
+	}
+	bw := bufio.NewWriter(w)
+	if len(cs.MCN) > 0 {
+		fmt.Fprintf(bw, "CATALOG %s\n", cs.MCN)
+	}
+	fmt.Fprintf(bw, "FILE \"%s\" WAVE\n", audioFilename)
+	for i, track := range cs.Tracks {
+		if i == len(cs.Tracks)-1 {
+			// Lead-out track.
+			break
+		}
+		trackType := "AUDIO"
+		if !track.IsAudio {
+			trackType = "MODE1/2352"
+		}
+		fmt.Fprintf(bw, "  TRACK %02d %s\n", track.TrackNum, trackType)
+		if track.HasPreEmphasis {
+			fmt.Fprintln(bw, "    FLAGS PRE")
+		}
+		if len(track.ISRC) > 0 {
+			fmt.Fprintf(bw, "    ISRC %s\n", track.ISRC)
+		}
+		for _, trackIndex := range track.TrackIndexes {
+			// Convert the absolute sample offset into a number of CD frames.
+			frames := (track.Offset + trackIndex.Offset) * 75 / uint64(sampleRate)
+			mm, ss, ff := frames/(60*75), frames/75%60, frames%75
+			fmt.Fprintf(bw, "    INDEX %02d %02d:%02d:%02d\n", trackIndex.IndexPointNum, mm, ss, ff)
+		}
+	}
+	return bw.Flush()
+}
+
+// ParseCueText parses the provided textual .cue file and returns a new CueSheet
+// metadata block. The MM:SS:FF timecodes of the index points are converted into
+// sample offsets using the provided sample rate. The lead-out track is located
+// at the provided total number of samples (e.g. as given by the StreamInfo
+// metadata block), which is required to determine the length of the last
+// track.
+//
+// If isCD is set, the cue sheet is a CD-DA cue sheet with a lead-in of leadIn
+// samples, in which case the sample rate must be 44.1kHz and all offsets must
+// be evenly divisible by 588 samples; otherwise, leadIn must be 0. Only cue
+// sheets referring to a single audio file are supported.
+func ParseCueText(r io.Reader, sampleRate uint32, sampleCount uint64, isCD bool, leadIn uint64) (cs *CueSheet, err error) {
+	if sampleRate == 0 {
+		return nil, errors.New("meta.ParseCueText: invalid sample rate; expected > 0, got 0")
+	}
+	if sampleCount == 0 {
+		return nil, errors.New("meta.ParseCueText: invalid total number of samples; expected > 0, got 0")
+	}
+	if isCD && sampleRate != 44100 {
+		return nil, fmt.Errorf("meta.ParseCueText: invalid sample rate for CD-DA; expected 44100, got %d", sampleRate)
+	}
+	cs = &CueSheet{IsCompactDisc: isCD, LeadInSampleCount: leadIn}
+
+	var track *CueSheetTrack
+	var hasFile bool
+	s := bufio.NewScanner(r)
+	for lineNum := 1; s.Scan(); lineNum++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "CATALOG":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("meta.ParseCueText: invalid CATALOG command on line %d", lineNum)
+			}
+			cs.MCN = fields[1]
+		case "FILE":
+			if hasFile {
+				return nil, fmt.Errorf("meta.ParseCueText: multiple FILE commands not supported; on line %d", lineNum)
+			}
+			hasFile = true
+		case "TRACK":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("meta.ParseCueText: invalid TRACK command on line %d", lineNum)
+			}
+			num, err := strconv.ParseUint(fields[1], 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("meta.ParseCueText: invalid track number on line %d; %v", lineNum, err)
+			}
+			cs.Tracks = append(cs.Tracks, CueSheetTrack{
+				TrackNum: uint8(num),
+				IsAudio:  strings.EqualFold(fields[2], "AUDIO"),
+			})
+			track = &cs.Tracks[len(cs.Tracks)-1]
+		case "ISRC":
+			if track == nil || len(fields) != 2 {
+				return nil, fmt.Errorf("meta.ParseCueText: invalid ISRC command on line %d", lineNum)
+			}
+			track.ISRC = fields[1]
+		case "FLAGS":
+			if track == nil {
+				return nil, fmt.Errorf("meta.ParseCueText: invalid FLAGS command on line %d", lineNum)
+			}
+			for _, flag := range fields[1:] {
+				if strings.EqualFold(flag, "PRE") {
+					track.HasPreEmphasis = true
+				}
+			}
+		case "INDEX":
+			if track == nil || len(fields) != 3 {
+				return nil, fmt.Errorf("meta.ParseCueText: invalid INDEX command on line %d", lineNum)
+			}
+			num, err := strconv.ParseUint(fields[1], 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("meta.ParseCueText: invalid index point number on line %d; %v", lineNum, err)
+			}
+			offset, err := parseTimecode(fields[2], sampleRate)
+			if err != nil {
+				return nil, fmt.Errorf("meta.ParseCueText: invalid index point on line %d; %v", lineNum, err)
+			}
+			if offset >= sampleCount {
+				return nil, fmt.Errorf("meta.ParseCueText: invalid index point on line %d; offset %d past end of stream of %d samples", lineNum, offset, sampleCount)
+			}
+			// The track offset is the offset of the first index point of the
+			// track, and the index point offsets are relative to it.
+			if len(track.TrackIndexes) == 0 {
+				track.Offset = offset
+			}
+			if offset < track.Offset {
+				return nil, fmt.Errorf("meta.ParseCueText: invalid index point on line %d; offset before start of track", lineNum)
+			}
+			track.TrackIndexes = append(track.TrackIndexes, CueSheetTrackIndex{
+				Offset:        offset - track.Offset,
+				IndexPointNum: uint8(num),
+			})
+			track.TrackIndexCount = uint8(len(track.TrackIndexes))
+		}
+	}
+	err = s.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	// Lead-out track.
+	leadOut := CueSheetTrack{TrackNum: 255, Offset: sampleCount, IsAudio: true}
+	if cs.IsCompactDisc {
+		leadOut.TrackNum = 170
+	}
+	cs.Tracks = append(cs.Tracks, leadOut)
+	cs.TrackCount = uint8(len(cs.Tracks))
+
+	err = cs.verify()
+	if err != nil {
+		return nil, fmt.Errorf("meta.ParseCueText: %w", err)
+	}
+	return cs, nil
+}
+
+// parseTimecode parses the provided MM:SS:FF timecode, where FF is the number
+// of CD frames (1/75th of a second), and returns the corresponding sample
+// offset.
+func parseTimecode(s string, sampleRate uint32) (offset uint64, err error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid timecode %q; expected MM:SS:FF", s)
+	}
+	var x [3]uint64
+	for i, part := range parts {
+		x[i], err = strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timecode %q; %v", s, err)
+		}
+	}
+	mm, ss, ff := x[0], x[1], x[2]
+	if ss >= 60 || ff >= 75 {
+		return 0, fmt.Errorf("invalid timecode %q; seconds or frames out of range", s)
+	}
+	frames := (mm*60+ss)*75 + ff
+	return frames * uint64(sampleRate) / 75, nil
+}
+
+// verify verifies that the cue sheet adheres to the rules of the
+// specification, which are enforced by ParseCueSheet. The returned errors are
+// not prefixed by the name of the calling function.
+func (cs *CueSheet) verify() error {
+	if !cs.IsCompactDisc && cs.LeadInSampleCount != 0 {
+		return fmt.Errorf("invalid lead-in sample count for non CD-DA; expected 0, got %d", cs.LeadInSampleCount)
+	}
+	if len(cs.Tracks) < 1 {
+		return errors.New("at least one track (the lead-out track) is required")
+	}
+	if cs.IsCompactDisc && len(cs.Tracks) > 100 {
+		return fmt.Errorf("too many tracks for CD-DA cue sheet; expected <= 100, got %d", len(cs.Tracks))
+	}
+	for i, track := range cs.Tracks {
+		isLeadOut := i == len(cs.Tracks)-1
+		if cs.IsCompactDisc && track.Offset%588 != 0 {
+			return fmt.Errorf("invalid track offset (%d) for CD-DA; must be evenly divisible by 588", track.Offset)
+		}
+		if track.TrackNum == 0 {
+			return errors.New("track number 0 not allowed")
+		}
+		switch {
+		case cs.IsCompactDisc && isLeadOut && track.TrackNum != 170:
+			return fmt.Errorf("invalid lead-out track number for CD-DA; expected 170, got %d", track.TrackNum)
+		case cs.IsCompactDisc && !isLeadOut && track.TrackNum > 99:
+			return fmt.Errorf("invalid track number for CD-DA; expected <= 99, got %d", track.TrackNum)
+		case !cs.IsCompactDisc && isLeadOut && track.TrackNum != 255:
+			return fmt.Errorf("invalid lead-out track number for non CD-DA; expected 255, got %d", track.TrackNum)
+		}
+		if isLeadOut {
+			if len(track.TrackIndexes) != 0 {
+				return fmt.Errorf("invalid number of track points for the lead-out track; expected 0, got %d", len(track.TrackIndexes))
+			}
+			continue
+		}
+		if len(track.TrackIndexes) < 1 {
+			return fmt.Errorf("invalid number of track points; expected >= 1, got %d", len(track.TrackIndexes))
+		}
+		if cs.IsCompactDisc && len(track.TrackIndexes) > 100 {
+			return fmt.Errorf("invalid number of track points for CD-DA; expected <= 100, got %d", len(track.TrackIndexes))
+		}
+		for _, trackIndex := range track.TrackIndexes {
+			if cs.IsCompactDisc && trackIndex.Offset%588 != 0 {
+				return fmt.Errorf("invalid track index offset (%d) for CD-DA; must be evenly divisible by 588", trackIndex.Offset)
+			}
+		}
+	}
+	return nil
+}
+
 // getStringFromSZ converts the provided byte slice to a string after
 // terminating it at the first occurance of a NULL character.
 func getStringFromSZ(buf []byte) string {
@@ -0,0 +1,44 @@
+package meta
+
+import (
+	"encoding/json"
+)
+
+// MarshalText returns the name of the block type; e.g. "vorbis comment". It
+// implements the encoding.TextMarshaler interface, which is used to encode
+// block types as JSON strings.
+func (t BlockType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// MarshalJSON returns the JSON encoding of the StreamInfo metadata block, with
+// the MD5 signature encoded as a lowercase hexadecimal string.
+func (si *StreamInfo) MarshalJSON() ([]byte, error) {
+	// streamInfo has the fields but not the methods of StreamInfo, to prevent
+	// infinite recursion.
+	type streamInfo StreamInfo
+	v := struct {
+		*streamInfo
+		MD5sum string
+	}{
+		streamInfo: (*streamInfo)(si),
+		MD5sum:     si.MD5String(),
+	}
+	return json.Marshal(v)
+}
+
+// MarshalJSON returns the JSON encoding of the Application metadata block, with
+// the application ID encoded as by Application.IDString and the application
+// data encoded as a base64 string.
+func (app *Application) MarshalJSON() ([]byte, error) {
+	v := struct {
+		ID   string
+		Name string
+		Data []byte
+	}{
+		ID:   app.IDString(),
+		Name: app.Name(),
+		Data: app.Data,
+	}
+	return json.Marshal(v)
+}
@@ -2,20 +2,52 @@
 package meta
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"io/ioutil"
-	"log"
-	"os"
+	"reflect"
+	"sort"
+)
 
-	"github.com/eaburns/bit"
+// Errors returned when parsing metadata block headers and bodies. Use
+// errors.Is to check for them, as they are wrapped with additional context.
+var (
+	// ErrInvalidBlockType is returned when a metadata block header has an
+	// invalid block type.
+	ErrInvalidBlockType = errors.New("meta: invalid block type")
+	// ErrReservedInvalidBlockType is returned when a metadata block header has
+	// the invalid block type 127, which is used to avoid confusion with a frame
+	// sync code. It wraps ErrInvalidBlockType. In practice it commonly
+	// indicates that parsing has run past the metadata blocks into the audio
+	// frames, e.g. because of a corrupt or misparsed IsLast flag.
+	ErrReservedInvalidBlockType = fmt.Errorf("%w 127; likely a frame sync code", ErrInvalidBlockType)
+	// ErrUnsupportedBlockType is returned when the body of a metadata block has
+	// a block type which cannot be parsed.
+	ErrUnsupportedBlockType = errors.New("meta: unsupported block type")
+	// ErrBlockTooLarge is returned when the length of a metadata block body
+	// exceeds MaxBlockSize.
+	ErrBlockTooLarge = errors.New("meta: block too large")
 )
 
+// MaxBlockSize is the maximum length in bytes of metadata block bodies parsed
+// by Block.Parse. Blocks which declare a larger length are rejected before the
+// body is read, to protect against corrupt or malicious files. The bodies of
+// padding blocks are verified without being stored, and are therefore exempt.
+//
+// The default value of 16777215 (2^24-1) bytes is the largest length which may
+// be stored in a metadata block header.
+var MaxBlockSize = 1<<24 - 1
+
 // A Block is a metadata block, consisting of a block header and a block body.
 type Block struct {
 	// The underlying reader of the block.
 	r io.Reader
+	// Offset in bytes of the block body within the underlying reader, or -1 if
+	// the underlying reader is not an io.Seeker.
+	bodyOffset int64
 	// Metadata block header.
 	Header *BlockHeader
 	// Metadata block body: *StreamInfo, *Application, *SeekTable, etc.
@@ -44,38 +76,107 @@ func ParseBlock(r io.Reader) (block *Block, err error) {
 // metadata block body and Block.Skip to ignore it.
 func NewBlock(r io.Reader) (block *Block, err error) {
 	// Read metadata block header.
-	block = &Block{r: r}
+	block = &Block{r: r, bodyOffset: -1}
 	block.Header, err = ParseBlockHeader(r)
 	if err != nil {
 		return nil, err
 	}
 
+	// Record the offset of the block body, to allow re-parsing it.
+	if rs, ok := r.(io.Seeker); ok {
+		block.bodyOffset, err = rs.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return block, nil
 }
 
 // Parse reads and parses the metadata block body.
 func (block *Block) Parse() (err error) {
+	return block.ParseOptions(nil)
+}
+
+// ParseOptions reads and parses the metadata block body, using the provided
+// parse options. A nil opts is equivalent to the default options of
+// Block.Parse. An error wrapping io.ErrUnexpectedEOF is returned if the stream
+// ends before Header.Length bytes have been read.
+func (block *Block) ParseOptions(opts *Options) (err error) {
+	if block.Header.BlockType != TypePadding && block.Header.Length > MaxBlockSize {
+		return fmt.Errorf("meta.Block.ParseOptions: %w; %v block of %d bytes exceeds limit of %d bytes", ErrBlockTooLarge, block.Header.BlockType, block.Header.Length, MaxBlockSize)
+	}
+
 	// Read metadata block.
-	lr := io.LimitReader(block.r, int64(block.Header.Length))
+	er := &eofReader{r: block.r}
+	lr := &io.LimitedReader{R: er, N: int64(block.Header.Length)}
 	switch block.Header.BlockType {
 	case TypeStreamInfo:
 		block.Body, err = ParseStreamInfo(lr)
 	case TypePadding:
 		err = VerifyPadding(lr)
+		if errors.Is(err, ErrInvalidPadding) && opts != nil && opts.Warn != nil {
+			// Tolerate invalid padding when warnings are reported.
+			opts.warnf("meta.Block.ParseOptions: invalid padding; must contain only zeroes")
+			_, err = io.Copy(ioutil.Discard, lr)
+		}
 	case TypeApplication:
 		block.Body, err = ParseApplication(lr)
 	case TypeSeekTable:
 		block.Body, err = ParseSeekTable(lr)
 	case TypeVorbisComment:
-		block.Body, err = ParseVorbisComment(lr)
+		var vc *VorbisComment
+		vc, err = ParseVorbisComment(lr)
+		if err == nil {
+			vc.checkUTF8(opts)
+			block.Body = vc
+		}
 	case TypeCueSheet:
-		block.Body, err = ParseCueSheet(lr)
+		var cs *CueSheet
+		cs, err = ParseCueSheet(lr)
+		if err == nil {
+			for _, track := range cs.Tracks {
+				if !track.ValidISRC() {
+					opts.warnf("meta.Block.ParseOptions: invalid ISRC %q of track %d", track.ISRC, track.TrackNum)
+				}
+			}
+			block.Body = cs
+		}
 	case TypePicture:
-		block.Body, err = ParsePicture(lr)
+		if opts != nil && opts.PictureMetadataOnly {
+			block.Body, err = block.parsePictureMetadata(lr)
+		} else {
+			block.Body, err = ParsePicture(lr)
+		}
 	case TypeReserved:
-		block.Body, err = ioutil.ReadAll(lr)
+		parse, ok := lookupBlockParser(block.Header.RawType)
+		switch {
+		case !ok:
+			block.Body, err = ioutil.ReadAll(lr)
+		case opts != nil && opts.TolerateUnknown:
+			// Buffer the block body, to fall back to the raw bytes if the
+			// registered block parser fails.
+			var buf []byte
+			buf, err = ioutil.ReadAll(lr)
+			if err == nil && lr.N == 0 {
+				block.Body, err = parse(bytes.NewReader(buf), block.Header.Length)
+				if err != nil {
+					opts.warnf("meta.Block.ParseOptions: reserved block type %d read as raw bytes; %v", block.Header.RawType, err)
+					block.Body, err = buf, nil
+				}
+			}
+		default:
+			block.Body, err = parse(lr, block.Header.Length)
+		}
 	default:
-		return fmt.Errorf("meta.Block.ParseBlock: block type '%d' not yet supported", block.Header.BlockType)
+		return fmt.Errorf("meta.Block.ParseOptions: %w; block type '%d' not yet supported", ErrUnsupportedBlockType, block.Header.BlockType)
+	}
+	if er.eof && lr.N > 0 {
+		// The stream ended before Header.Length bytes were read; the
+		// sub-parsers may otherwise report confusing errors or return partial
+		// block bodies.
+		block.Body = nil
+		return fmt.Errorf("meta.Block.ParseOptions: %w; %v block truncated, %d of %d bytes missing", io.ErrUnexpectedEOF, block.Header.BlockType, lr.N, block.Header.Length)
 	}
 	if err != nil {
 		return err
@@ -84,20 +185,298 @@ func (block *Block) Parse() (err error) {
 	return nil
 }
 
-// Skip ignores the contents of the metadata block body.
-func (block *Block) Skip() (err error) {
-	if r, ok := block.r.(io.Seeker); ok {
-		_, err = r.Seek(int64(block.Header.Length), os.SEEK_CUR)
+// ReParse seeks back to the start of the metadata block body and parses it,
+// which allows a block to be parsed after it has been skipped. The position of
+// the underlying reader is restored afterwards, even if parsing fails. An error
+// is returned if the underlying reader is not an io.Seeker.
+func (block *Block) ReParse() (err error) {
+	rs, ok := block.r.(io.Seeker)
+	if !ok || block.bodyOffset < 0 {
+		return errors.New("meta.Block.ReParse: unable to seek to block body; underlying reader is not an io.Seeker")
+	}
+	cur, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, serr := rs.Seek(cur, io.SeekStart)
+		if err == nil {
+			err = serr
+		}
+	}()
+	_, err = rs.Seek(block.bodyOffset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	return block.Parse()
+}
+
+// parsePictureMetadata parses the metadata of a Picture metadata block body
+// from lr, which is limited to the block body, and skips the picture data.
+func (block *Block) parsePictureMetadata(lr *io.LimitedReader) (pic *Picture, err error) {
+	pic, dataLen, err := parsePictureMetadata(lr)
+	if err != nil {
+		return nil, err
+	}
+	if int64(dataLen) != lr.N {
+		return nil, fmt.Errorf("meta.Block.parsePictureMetadata: invalid data length; expected %d, got %d", lr.N, dataLen)
+	}
+	pic.dataLen = int(dataLen)
+	pic.dataOffset = -1
+	if block.bodyOffset >= 0 {
+		pic.dataOffset = block.bodyOffset + int64(block.Header.Length) - lr.N
+	}
+	n, err := skip(block.r, lr.N)
+	lr.N -= n
+	if err != nil {
+		return nil, err
+	}
+	return pic, nil
+}
+
+// CopyBody copies the metadata block body from the underlying reader to w,
+// without parsing or storing it, and returns the number of bytes copied. It is
+// intended for preserving blocks which are not understood, such as reserved
+// blocks, and should be called in place of Block.Parse or Block.Skip. An error
+// wrapping io.ErrUnexpectedEOF is returned if the stream ends before
+// Header.Length bytes have been copied.
+func (block *Block) CopyBody(w io.Writer) (n int64, err error) {
+	length := int64(block.Header.Length)
+	n, err = io.CopyN(w, block.r, length)
+	if err == io.EOF {
+		return n, fmt.Errorf("meta.Block.CopyBody: %w; %v block truncated, %d of %d bytes missing", io.ErrUnexpectedEOF, block.Header.BlockType, length-n, length)
+	}
+	return n, err
+}
+
+// Skip ignores the contents of the metadata block body. It returns the number
+// of bytes skipped. An error wrapping io.ErrUnexpectedEOF is returned if the
+// stream ends before Header.Length bytes have been skipped.
+func (block *Block) Skip() (n int64, err error) {
+	return skip(block.r, int64(block.Header.Length))
+}
+
+// skip skips length bytes of the provided io.Reader, seeking if possible. It
+// returns the number of bytes skipped.
+func skip(r io.Reader, length int64) (n int64, err error) {
+	if r, ok := r.(io.Seeker); ok {
+		// Seeking past the end of the stream is not an error, so verify the
+		// length of the remaining stream beforehand.
+		cur, err := r.Seek(0, io.SeekCurrent)
 		if err != nil {
-			return err
+			return 0, err
+		}
+		end, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, err
+		}
+		if cur+length > end {
+			// The stream is left positioned at its end.
+			return end - cur, fmt.Errorf("meta.Block.Skip: %w; expected %d bytes, got %d", io.ErrUnexpectedEOF, length, end-cur)
 		}
-	} else {
-		_, err = io.CopyN(ioutil.Discard, block.r, int64(block.Header.Length))
+		_, err = r.Seek(cur+length, io.SeekStart)
 		if err != nil {
-			return err
+			return 0, err
 		}
+		return length, nil
 	}
-	return nil
+	n, err = io.CopyN(ioutil.Discard, r, length)
+	if err == io.EOF {
+		return n, fmt.Errorf("meta.Block.Skip: %w; expected %d bytes, got %d", io.ErrUnexpectedEOF, length, n)
+	}
+	return n, err
+}
+
+// WriteTo writes the metadata block to w; both the header and the body. The
+// body is encoded based on its concrete type and the length of the written
+// header is computed from the encoded body, rather than relying on the
+// Header.Length field. Reserved blocks are written using the block type number
+// of Header.RawType. It returns the number of bytes written.
+//
+// WriteTo implements the io.WriterTo interface.
+func (block *Block) WriteTo(w io.Writer) (n int64, err error) {
+	raw, body, err := block.encodeBody()
+	if err != nil {
+		return 0, err
+	}
+	if len(body) > 0xFFFFFF {
+		return 0, fmt.Errorf("meta.Block.WriteTo: invalid block body length; expected <= %d, got %d", 0xFFFFFF, len(body))
+	}
+
+	// Write metadata block header.
+	var hdr [4]byte
+	hdr[0] = raw
+	if block.Header.IsLast {
+		hdr[0] |= 0x80
+	}
+	putUint24(hdr[1:], uint32(len(body)))
+	m, err := w.Write(hdr[:])
+	n += int64(m)
+	if err != nil {
+		return n, err
+	}
+
+	// Write metadata block body.
+	m, err = w.Write(body)
+	n += int64(m)
+	if err != nil {
+		return n, err
+	}
+
+	return n, nil
+}
+
+// MarshaledLen returns the length in bytes of the metadata block body as it
+// would currently be written by Block.WriteTo. Unlike Header.Length, which
+// holds the length read from the stream, it reflects any edits made to the
+// block body since it was parsed.
+func (block *Block) MarshaledLen() (int, error) {
+	_, body, err := block.encodeBody()
+	if err != nil {
+		return 0, err
+	}
+	return len(body), nil
+}
+
+// Fingerprint returns a 64-bit FNV-1a hash of the block type number and the
+// binary representation of the metadata block body, as written by
+// Block.WriteTo. Structurally identical blocks have the same fingerprint,
+// regardless of the IsLast flag and the position of the blocks within their
+// streams, which allows callers caching parsed metadata to detect changed
+// blocks. Unlike Block.Equal, the order of Vorbis comment entries is
+// significant. Fingerprint is not a cryptographic hash, and is not suitable for
+// detecting deliberate tampering.
+//
+// The fingerprint depends on the Marshal method of the block body; 0 is
+// returned if the block body cannot be marshaled, e.g. if it has not been
+// parsed.
+func (block *Block) Fingerprint() uint64 {
+	raw, body, err := block.encodeBody()
+	if err != nil {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte{raw})
+	h.Write(body)
+	return h.Sum64()
+}
+
+// encodeBody returns the block type number and binary representation of the
+// metadata block body, based on the concrete type of block.Body.
+func (block *Block) encodeBody() (raw uint8, buf []byte, err error) {
+	switch body := block.Body.(type) {
+	case *StreamInfo:
+		buf, err = body.Marshal()
+		return rawBlockType[TypeStreamInfo], buf, err
+	case *Application:
+		buf, err = body.Marshal()
+		return rawBlockType[TypeApplication], buf, err
+	case *SeekTable:
+		buf, err = body.Marshal()
+		return rawBlockType[TypeSeekTable], buf, err
+	case *VorbisComment:
+		buf, err = body.Marshal()
+		return rawBlockType[TypeVorbisComment], buf, err
+	case *CueSheet:
+		buf, err = body.Marshal()
+		return rawBlockType[TypeCueSheet], buf, err
+	case *Picture:
+		buf, err = body.Marshal()
+		return rawBlockType[TypePicture], buf, err
+	case []byte:
+		// Reserved blocks are written back as is, using their original block
+		// type number.
+		if block.Header.BlockType != TypeReserved || block.Header.RawType < 7 || block.Header.RawType > 126 {
+			return 0, nil, fmt.Errorf("meta.Block.encodeBody: invalid reserved block type number; expected >= 7 and <= 126, got %d", block.Header.RawType)
+		}
+		return block.Header.RawType, body, nil
+	case nil:
+		// Padding blocks have no body; their length is given by the header.
+		if block.Header.BlockType == TypePadding {
+			if block.Header.Length < 0 || block.Header.Length > maxPaddingLength {
+				return 0, nil, fmt.Errorf("meta.Block.encodeBody: invalid padding length; expected >= 0 and <= %d, got %d", maxPaddingLength, block.Header.Length)
+			}
+			return rawBlockType[TypePadding], make([]byte, block.Header.Length), nil
+		}
+		return 0, nil, fmt.Errorf("meta.Block.encodeBody: unable to write %v block; block body not parsed", block.Header.BlockType)
+	case interface{ Marshal() ([]byte, error) }:
+		// Custom bodies of reserved blocks, as parsed by registered block
+		// parsers.
+		if block.Header.BlockType != TypeReserved || block.Header.RawType < 7 || block.Header.RawType > 126 {
+			return 0, nil, fmt.Errorf("meta.Block.encodeBody: invalid reserved block type number; expected >= 7 and <= 126, got %d", block.Header.RawType)
+		}
+		buf, err = body.Marshal()
+		return block.Header.RawType, buf, err
+	}
+	return 0, nil, fmt.Errorf("meta.Block.encodeBody: unsupported block body type %T", block.Body)
+}
+
+// Equal reports whether the two metadata blocks are structurally equal. The
+// block headers are compared by block type and length, ignoring the IsLast flag
+// since it depends on the position of the block within the stream. The block
+// bodies are compared by their meaningful fields; e.g. the entries of Vorbis
+// comments are compared regardless of order.
+func (block *Block) Equal(other *Block) bool {
+	if block == nil || other == nil {
+		return block == other
+	}
+	if block.Header == nil || other.Header == nil {
+		if block.Header != other.Header {
+			return false
+		}
+	} else if block.Header.BlockType != other.Header.BlockType || block.Header.Length != other.Header.Length {
+		return false
+	}
+	return bodyEqual(block.Body, other.Body)
+}
+
+// bodyEqual reports whether the two metadata block bodies are structurally
+// equal.
+func bodyEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case *StreamInfo:
+		b, ok := b.(*StreamInfo)
+		return ok && *a == *b
+	case *Application:
+		b, ok := b.(*Application)
+		return ok && a.ID == b.ID && bytes.Equal(a.Data, b.Data)
+	case *VorbisComment:
+		b, ok := b.(*VorbisComment)
+		if !ok || a.Vendor != b.Vendor || len(a.Entries) != len(b.Entries) {
+			return false
+		}
+		x, y := sortedEntries(a.Entries), sortedEntries(b.Entries)
+		for i := range x {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	case *Picture:
+		b, ok := b.(*Picture)
+		return ok && a.Type == b.Type && a.MIME == b.MIME && a.Desc == b.Desc && a.Width == b.Width && a.Height == b.Height && a.ColorDepth == b.ColorDepth && a.ColorCount == b.ColorCount && bytes.Equal(a.Data, b.Data)
+	case []byte:
+		b, ok := b.([]byte)
+		return ok && bytes.Equal(a, b)
+	}
+	// *SeekTable, *CueSheet.
+	return reflect.DeepEqual(a, b)
+}
+
+// sortedEntries returns a sorted copy of the provided Vorbis comment entries,
+// ordered by name and value.
+func sortedEntries(entries []VorbisEntry) []VorbisEntry {
+	sorted := make([]VorbisEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].Value < sorted[j].Value
+	})
+	return sorted
 }
 
 // BlockType is used to identify the metadata block type.
@@ -129,6 +508,19 @@ var blockTypeName = map[BlockType]string{
 	TypeVorbisComment: "vorbis comment",
 	TypeCueSheet:      "cue sheet",
 	TypePicture:       "picture",
+	TypeReserved:      "reserved",
+}
+
+// rawBlockType is a map from BlockType to the block type number used in the
+// metadata block header.
+var rawBlockType = map[BlockType]uint8{
+	TypeStreamInfo:    0,
+	TypePadding:       1,
+	TypeApplication:   2,
+	TypeSeekTable:     3,
+	TypeVorbisComment: 4,
+	TypeCueSheet:      5,
+	TypePicture:       6,
 }
 
 func (t BlockType) String() string {
@@ -145,7 +537,16 @@ type BlockHeader struct {
 	IsLast bool
 	// Block type.
 	BlockType BlockType
-	// Length in bytes of the metadata body.
+	// RawType is the 7-bit block type number as stored in the stream; 0-6 for
+	// the known block types and 7-126 for reserved block types. It is used to
+	// tell reserved block types apart since they all share the TypeReserved
+	// BlockType.
+	RawType uint8
+	// Length in bytes of the metadata body, as stored in the stream. Edits to
+	// the block body invalidate Length until the block is re-encoded; use
+	// Block.MarshaledLen to get the length of the current block body. Length
+	// should be treated as read-only, except for padding blocks which have no
+	// body; see Block.SetPaddingLength.
 	Length int
 }
 
@@ -161,18 +562,20 @@ type BlockHeader struct {
 //
 // ref: http://flac.sourceforge.net/format.html#metadata_block_header
 func ParseBlockHeader(r io.Reader) (h *BlockHeader, err error) {
-	br := bit.NewReader(r)
-	// field 0: is_last    (1 bit)
-	// field 1: block_type (7 bits)
-	// field 2: length     (24 bits)
-	fields, err := br.ReadFields(1, 7, 24)
+	// The block header has a fixed size of 4 bytes, which are decoded directly
+	// to avoid allocating a bit reader per metadata block.
+	//    byte 0, bit 7:    is_last    (1 bit)
+	//    byte 0, bits 0-6: block_type (7 bits)
+	//    bytes 1-3:        length     (24 bits)
+	var buf [4]byte
+	_, err = io.ReadFull(r, buf[:])
 	if err != nil {
 		return nil, err
 	}
 
 	// Is last.
 	h = new(BlockHeader)
-	if fields[0] != 0 {
+	if buf[0]&0x80 != 0 {
 		h.IsLast = true
 	}
 
@@ -186,7 +589,8 @@ func ParseBlockHeader(r io.Reader) (h *BlockHeader, err error) {
 	//    6:     Picture
 	//    7-126: reserved
 	//    127:   invalid, to avoid confusion with a frame sync code
-	blockType := fields[1]
+	blockType := buf[0] & 0x7F
+	h.RawType = blockType
 	switch blockType {
 	case 0:
 		h.BlockType = TypeStreamInfo
@@ -205,16 +609,15 @@ func ParseBlockHeader(r io.Reader) (h *BlockHeader, err error) {
 	default:
 		if blockType >= 7 && blockType <= 126 {
 			// block type 7-126: reserved.
-			log.Printf("meta.ParseBlockHeader: reserved block type %d.\n", uint8(blockType))
 			h.BlockType = TypeReserved
 		} else {
 			// block type 127: invalid.
-			return nil, errors.New("meta.ParseBlockHeader: invalid block type")
+			return nil, fmt.Errorf("meta.ParseBlockHeader: %w", ErrReservedInvalidBlockType)
 		}
 	}
 
 	// Length.
 	// int won't overflow since the max value of Length is 0x00FFFFFF.
-	h.Length = int(fields[2])
+	h.Length = int(buf[1])<<16 | int(buf[2])<<8 | int(buf[3])
 	return h, nil
 }
@@ -2,10 +2,19 @@ package meta_test
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
 	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/eaburns/bit"
 	"github.com/mewkiz/flac"
 	"github.com/mewkiz/flac/meta"
 )
@@ -19,11 +28,11 @@ var golden = []struct {
 		name: "../testdata/59996.flac",
 		blocks: []*meta.Block{
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, Length: 34},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, RawType: 0, Length: 34},
 				Body:   &meta.StreamInfo{BlockSizeMin: 0x1000, BlockSizeMax: 0x1000, FrameSizeMin: 0x44c5, FrameSizeMax: 0x4588, SampleRate: 0xac44, ChannelCount: 0x2, BitsPerSample: 0x18, SampleCount: 0x2000, MD5sum: [16]uint8{0x95, 0xba, 0xe5, 0xe2, 0xc7, 0x45, 0xbb, 0x3c, 0xa9, 0x5c, 0xa3, 0xb1, 0x35, 0xc9, 0x43, 0xf4}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x10, Length: 202},
+				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x10, RawType: 4, Length: 202},
 				Body:   &meta.VorbisComment{Vendor: "reference libFLAC 1.2.1 20070917", Entries: []meta.VorbisEntry{meta.VorbisEntry{Name: "Description", Value: "Waving a bamboo staff"}, meta.VorbisEntry{Name: "YEAR", Value: "2008"}, meta.VorbisEntry{Name: "ARTIST", Value: "qubodup aka Iwan Gabovitch | qubodup@gmail.com"}, meta.VorbisEntry{Name: "COMMENTS", Value: "I release this file into the public domain"}}},
 			},
 		},
@@ -34,11 +43,11 @@ var golden = []struct {
 		name: "../testdata/172960.flac",
 		blocks: []*meta.Block{
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, Length: 34},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, RawType: 0, Length: 34},
 				Body:   &meta.StreamInfo{BlockSizeMin: 0x1000, BlockSizeMax: 0x1000, FrameSizeMin: 0xb7c, FrameSizeMax: 0x256b, SampleRate: 0x17700, ChannelCount: 0x2, BitsPerSample: 0x10, SampleCount: 0xaaa3, MD5sum: [16]uint8{0x76, 0x3d, 0xa8, 0xa5, 0xb7, 0x58, 0xe6, 0x2, 0x61, 0xb4, 0xd4, 0xc2, 0x88, 0x4d, 0x8e, 0xe}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x10, Length: 180},
+				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x10, RawType: 4, Length: 180},
 				Body:   &meta.VorbisComment{Vendor: "reference libFLAC 1.2.1 20070917", Entries: []meta.VorbisEntry{meta.VorbisEntry{Name: "GENRE", Value: "Sound Clip"}, meta.VorbisEntry{Name: "ARTIST", Value: "Iwan 'qubodup' Gabovitch"}, meta.VorbisEntry{Name: "Artist Homepage", Value: "http://qubodup.net"}, meta.VorbisEntry{Name: "Artist Email", Value: "qubodup@gmail.com"}, meta.VorbisEntry{Name: "DATE", Value: "2012"}}},
 			},
 		},
@@ -49,11 +58,11 @@ var golden = []struct {
 		name: "../testdata/189983.flac",
 		blocks: []*meta.Block{
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, Length: 34},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, RawType: 0, Length: 34},
 				Body:   &meta.StreamInfo{BlockSizeMin: 0x1200, BlockSizeMax: 0x1200, FrameSizeMin: 0x94d, FrameSizeMax: 0x264a, SampleRate: 0xac44, ChannelCount: 0x2, BitsPerSample: 0x10, SampleCount: 0x50f4, MD5sum: [16]uint8{0x63, 0x28, 0xed, 0x6d, 0xd3, 0xe, 0x55, 0xfb, 0xa5, 0x73, 0x69, 0x2b, 0xb7, 0x35, 0x73, 0xb7}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x10, Length: 40},
+				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x10, RawType: 4, Length: 40},
 				Body:   &meta.VorbisComment{Vendor: "reference libFLAC 1.2.1 20070917"},
 			},
 		},
@@ -64,27 +73,27 @@ var golden = []struct {
 		name: "testdata/input-SCPAP.flac",
 		blocks: []*meta.Block{
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, Length: 34},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, RawType: 0, Length: 34},
 				Body:   &meta.StreamInfo{BlockSizeMin: 0x1200, BlockSizeMax: 0x1200, FrameSizeMin: 0xe, FrameSizeMax: 0x10, SampleRate: 0xac44, ChannelCount: 0x2, BitsPerSample: 0x10, SampleCount: 0x16f8, MD5sum: [16]uint8{0x74, 0xff, 0xd4, 0x73, 0x7e, 0xb5, 0x48, 0x8d, 0x51, 0x2b, 0xe4, 0xaf, 0x58, 0x94, 0x33, 0x62}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x8, Length: 180},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x8, RawType: 3, Length: 180},
 				Body:   &meta.SeekTable{Points: []meta.SeekPoint{meta.SeekPoint{SampleNum: 0x0, Offset: 0x0, SampleCount: 0x1200}, meta.SeekPoint{SampleNum: 0x1200, Offset: 0xe, SampleCount: 0x4f8}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x20, Length: 540},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x20, RawType: 5, Length: 540},
 				Body:   &meta.CueSheet{MCN: "1234567890123", LeadInSampleCount: 0x15888, IsCompactDisc: true, TrackCount: 0x3, Tracks: []meta.CueSheetTrack{meta.CueSheetTrack{Offset: 0x0, TrackNum: 0x1, ISRC: "", IsAudio: true, HasPreEmphasis: false, TrackIndexCount: 0x2, TrackIndexes: []meta.CueSheetTrackIndex{meta.CueSheetTrackIndex{Offset: 0x0, IndexPointNum: 0x1}, meta.CueSheetTrackIndex{Offset: 0x24c, IndexPointNum: 0x2}}}, meta.CueSheetTrack{Offset: 0xb7c, TrackNum: 0x2, ISRC: "", IsAudio: true, HasPreEmphasis: false, TrackIndexCount: 0x1, TrackIndexes: []meta.CueSheetTrackIndex{meta.CueSheetTrackIndex{Offset: 0x0, IndexPointNum: 0x1}}}, meta.CueSheetTrack{Offset: 0x16f8, TrackNum: 0xaa, ISRC: "", IsAudio: true, HasPreEmphasis: false, TrackIndexCount: 0x0, TrackIndexes: []meta.CueSheetTrackIndex(nil)}}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x2, Length: 4},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x2, RawType: 1, Length: 4},
 				Body:   nil,
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x4, Length: 4},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x4, RawType: 2, Length: 4},
 				Body:   &meta.Application{ID: "fake", Data: []uint8{}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x2, Length: 3201},
+				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x2, RawType: 1, Length: 3201},
 				Body:   nil,
 			},
 		},
@@ -95,23 +104,23 @@ var golden = []struct {
 		name: "testdata/input-SCVA.flac",
 		blocks: []*meta.Block{
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, Length: 34},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, RawType: 0, Length: 34},
 				Body:   &meta.StreamInfo{BlockSizeMin: 0x1200, BlockSizeMax: 0x1200, FrameSizeMin: 0xe, FrameSizeMax: 0x10, SampleRate: 0xac44, ChannelCount: 0x2, BitsPerSample: 0x10, SampleCount: 0x16f8, MD5sum: [16]uint8{0x74, 0xff, 0xd4, 0x73, 0x7e, 0xb5, 0x48, 0x8d, 0x51, 0x2b, 0xe4, 0xaf, 0x58, 0x94, 0x33, 0x62}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x8, Length: 180},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x8, RawType: 3, Length: 180},
 				Body:   &meta.SeekTable{Points: []meta.SeekPoint{meta.SeekPoint{SampleNum: 0x0, Offset: 0x0, SampleCount: 0x1200}, meta.SeekPoint{SampleNum: 0x1200, Offset: 0xe, SampleCount: 0x4f8}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x20, Length: 540},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x20, RawType: 5, Length: 540},
 				Body:   &meta.CueSheet{MCN: "1234567890123", LeadInSampleCount: 0x15888, IsCompactDisc: true, TrackCount: 0x3, Tracks: []meta.CueSheetTrack{meta.CueSheetTrack{Offset: 0x0, TrackNum: 0x1, ISRC: "", IsAudio: true, HasPreEmphasis: false, TrackIndexCount: 0x2, TrackIndexes: []meta.CueSheetTrackIndex{meta.CueSheetTrackIndex{Offset: 0x0, IndexPointNum: 0x1}, meta.CueSheetTrackIndex{Offset: 0x24c, IndexPointNum: 0x2}}}, meta.CueSheetTrack{Offset: 0xb7c, TrackNum: 0x2, ISRC: "", IsAudio: true, HasPreEmphasis: false, TrackIndexCount: 0x1, TrackIndexes: []meta.CueSheetTrackIndex{meta.CueSheetTrackIndex{Offset: 0x0, IndexPointNum: 0x1}}}, meta.CueSheetTrack{Offset: 0x16f8, TrackNum: 0xaa, ISRC: "", IsAudio: true, HasPreEmphasis: false, TrackIndexCount: 0x0, TrackIndexes: []meta.CueSheetTrackIndex(nil)}}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x10, Length: 203},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x10, RawType: 4, Length: 203},
 				Body:   &meta.VorbisComment{Vendor: "reference libFLAC 1.1.3 20060805", Entries: []meta.VorbisEntry{meta.VorbisEntry{Name: "REPLAYGAIN_TRACK_PEAK", Value: "0.99996948"}, meta.VorbisEntry{Name: "REPLAYGAIN_TRACK_GAIN", Value: "-7.89 dB"}, meta.VorbisEntry{Name: "REPLAYGAIN_ALBUM_PEAK", Value: "0.99996948"}, meta.VorbisEntry{Name: "REPLAYGAIN_ALBUM_GAIN", Value: "-7.89 dB"}, meta.VorbisEntry{Name: "artist", Value: "1"}, meta.VorbisEntry{Name: "title", Value: "2"}}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x4, Length: 4},
+				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x4, RawType: 2, Length: 4},
 				Body:   &meta.Application{ID: "fake", Data: []uint8{}},
 			},
 		},
@@ -122,31 +131,31 @@ var golden = []struct {
 		name: "testdata/input-SCVAUP.flac",
 		blocks: []*meta.Block{
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, Length: 34},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, RawType: 0, Length: 34},
 				Body:   &meta.StreamInfo{BlockSizeMin: 0x1200, BlockSizeMax: 0x1200, FrameSizeMin: 0xe, FrameSizeMax: 0x10, SampleRate: 0xac44, ChannelCount: 0x2, BitsPerSample: 0x10, SampleCount: 0x16f8, MD5sum: [16]uint8{0x74, 0xff, 0xd4, 0x73, 0x7e, 0xb5, 0x48, 0x8d, 0x51, 0x2b, 0xe4, 0xaf, 0x58, 0x94, 0x33, 0x62}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x8, Length: 180},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x8, RawType: 3, Length: 180},
 				Body:   &meta.SeekTable{Points: []meta.SeekPoint{meta.SeekPoint{SampleNum: 0x0, Offset: 0x0, SampleCount: 0x1200}, meta.SeekPoint{SampleNum: 0x1200, Offset: 0xe, SampleCount: 0x4f8}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x20, Length: 540},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x20, RawType: 5, Length: 540},
 				Body:   &meta.CueSheet{MCN: "1234567890123", LeadInSampleCount: 0x15888, IsCompactDisc: true, TrackCount: 0x3, Tracks: []meta.CueSheetTrack{meta.CueSheetTrack{Offset: 0x0, TrackNum: 0x1, ISRC: "", IsAudio: true, HasPreEmphasis: false, TrackIndexCount: 0x2, TrackIndexes: []meta.CueSheetTrackIndex{meta.CueSheetTrackIndex{Offset: 0x0, IndexPointNum: 0x1}, meta.CueSheetTrackIndex{Offset: 0x24c, IndexPointNum: 0x2}}}, meta.CueSheetTrack{Offset: 0xb7c, TrackNum: 0x2, ISRC: "", IsAudio: true, HasPreEmphasis: false, TrackIndexCount: 0x1, TrackIndexes: []meta.CueSheetTrackIndex{meta.CueSheetTrackIndex{Offset: 0x0, IndexPointNum: 0x1}}}, meta.CueSheetTrack{Offset: 0x16f8, TrackNum: 0xaa, ISRC: "", IsAudio: true, HasPreEmphasis: false, TrackIndexCount: 0x0, TrackIndexes: []meta.CueSheetTrackIndex(nil)}}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x10, Length: 203},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x10, RawType: 4, Length: 203},
 				Body:   &meta.VorbisComment{Vendor: "reference libFLAC 1.1.3 20060805", Entries: []meta.VorbisEntry{meta.VorbisEntry{Name: "REPLAYGAIN_TRACK_PEAK", Value: "0.99996948"}, meta.VorbisEntry{Name: "REPLAYGAIN_TRACK_GAIN", Value: "-7.89 dB"}, meta.VorbisEntry{Name: "REPLAYGAIN_ALBUM_PEAK", Value: "0.99996948"}, meta.VorbisEntry{Name: "REPLAYGAIN_ALBUM_GAIN", Value: "-7.89 dB"}, meta.VorbisEntry{Name: "artist", Value: "1"}, meta.VorbisEntry{Name: "title", Value: "2"}}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x4, Length: 4},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x4, RawType: 2, Length: 4},
 				Body:   &meta.Application{ID: "fake", Data: []uint8{}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x80, Length: 0},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x80, RawType: 126, Length: 0},
 				Body:   nil,
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x2, Length: 3201},
+				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x2, RawType: 1, Length: 3201},
 				Body:   nil,
 			},
 		},
@@ -156,31 +165,31 @@ var golden = []struct {
 		name: "testdata/input-SCVPAP.flac",
 		blocks: []*meta.Block{
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, Length: 34},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, RawType: 0, Length: 34},
 				Body:   &meta.StreamInfo{BlockSizeMin: 0x1200, BlockSizeMax: 0x1200, FrameSizeMin: 0xe, FrameSizeMax: 0x10, SampleRate: 0xac44, ChannelCount: 0x2, BitsPerSample: 0x10, SampleCount: 0x16f8, MD5sum: [16]uint8{0x74, 0xff, 0xd4, 0x73, 0x7e, 0xb5, 0x48, 0x8d, 0x51, 0x2b, 0xe4, 0xaf, 0x58, 0x94, 0x33, 0x62}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x8, Length: 180},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x8, RawType: 3, Length: 180},
 				Body:   &meta.SeekTable{Points: []meta.SeekPoint{meta.SeekPoint{SampleNum: 0x0, Offset: 0x0, SampleCount: 0x1200}, meta.SeekPoint{SampleNum: 0x1200, Offset: 0xe, SampleCount: 0x4f8}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x20, Length: 540},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x20, RawType: 5, Length: 540},
 				Body:   &meta.CueSheet{MCN: "1234567890123", LeadInSampleCount: 0x15888, IsCompactDisc: true, TrackCount: 0x3, Tracks: []meta.CueSheetTrack{meta.CueSheetTrack{Offset: 0x0, TrackNum: 0x1, ISRC: "", IsAudio: true, HasPreEmphasis: false, TrackIndexCount: 0x2, TrackIndexes: []meta.CueSheetTrackIndex{meta.CueSheetTrackIndex{Offset: 0x0, IndexPointNum: 0x1}, meta.CueSheetTrackIndex{Offset: 0x24c, IndexPointNum: 0x2}}}, meta.CueSheetTrack{Offset: 0xb7c, TrackNum: 0x2, ISRC: "", IsAudio: true, HasPreEmphasis: false, TrackIndexCount: 0x1, TrackIndexes: []meta.CueSheetTrackIndex{meta.CueSheetTrackIndex{Offset: 0x0, IndexPointNum: 0x1}}}, meta.CueSheetTrack{Offset: 0x16f8, TrackNum: 0xaa, ISRC: "", IsAudio: true, HasPreEmphasis: false, TrackIndexCount: 0x0, TrackIndexes: []meta.CueSheetTrackIndex(nil)}}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x10, Length: 203},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x10, RawType: 4, Length: 203},
 				Body:   &meta.VorbisComment{Vendor: "reference libFLAC 1.1.3 20060805", Entries: []meta.VorbisEntry{meta.VorbisEntry{Name: "REPLAYGAIN_TRACK_PEAK", Value: "0.99996948"}, meta.VorbisEntry{Name: "REPLAYGAIN_TRACK_GAIN", Value: "-7.89 dB"}, meta.VorbisEntry{Name: "REPLAYGAIN_ALBUM_PEAK", Value: "0.99996948"}, meta.VorbisEntry{Name: "REPLAYGAIN_ALBUM_GAIN", Value: "-7.89 dB"}, meta.VorbisEntry{Name: "artist", Value: "1"}, meta.VorbisEntry{Name: "title", Value: "2"}}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x2, Length: 4},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x2, RawType: 1, Length: 4},
 				Body:   nil,
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x4, Length: 4},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x4, RawType: 2, Length: 4},
 				Body:   &meta.Application{ID: "fake", Data: []uint8{}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x2, Length: 3201},
+				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x2, RawType: 1, Length: 3201},
 				Body:   nil,
 			},
 		},
@@ -191,27 +200,27 @@ var golden = []struct {
 		name: "testdata/input-SVAUP.flac",
 		blocks: []*meta.Block{
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, Length: 34},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, RawType: 0, Length: 34},
 				Body:   &meta.StreamInfo{BlockSizeMin: 0x1200, BlockSizeMax: 0x1200, FrameSizeMin: 0xe, FrameSizeMax: 0x10, SampleRate: 0xac44, ChannelCount: 0x2, BitsPerSample: 0x10, SampleCount: 0x16f8, MD5sum: [16]uint8{0x74, 0xff, 0xd4, 0x73, 0x7e, 0xb5, 0x48, 0x8d, 0x51, 0x2b, 0xe4, 0xaf, 0x58, 0x94, 0x33, 0x62}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x8, Length: 180},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x8, RawType: 3, Length: 180},
 				Body:   &meta.SeekTable{Points: []meta.SeekPoint{meta.SeekPoint{SampleNum: 0x0, Offset: 0x0, SampleCount: 0x1200}, meta.SeekPoint{SampleNum: 0x1200, Offset: 0xe, SampleCount: 0x4f8}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}, meta.SeekPoint{SampleNum: 0xffffffffffffffff, Offset: 0x0, SampleCount: 0x0}}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x10, Length: 203},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x10, RawType: 4, Length: 203},
 				Body:   &meta.VorbisComment{Vendor: "reference libFLAC 1.1.3 20060805", Entries: []meta.VorbisEntry{meta.VorbisEntry{Name: "REPLAYGAIN_TRACK_PEAK", Value: "0.99996948"}, meta.VorbisEntry{Name: "REPLAYGAIN_TRACK_GAIN", Value: "-7.89 dB"}, meta.VorbisEntry{Name: "REPLAYGAIN_ALBUM_PEAK", Value: "0.99996948"}, meta.VorbisEntry{Name: "REPLAYGAIN_ALBUM_GAIN", Value: "-7.89 dB"}, meta.VorbisEntry{Name: "artist", Value: "1"}, meta.VorbisEntry{Name: "title", Value: "2"}}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x4, Length: 4},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x4, RawType: 2, Length: 4},
 				Body:   &meta.Application{ID: "fake", Data: []uint8{}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x80, Length: 0},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x80, RawType: 126, Length: 0},
 				Body:   nil,
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x2, Length: 3201},
+				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x2, RawType: 1, Length: 3201},
 				Body:   nil,
 			},
 		},
@@ -222,15 +231,15 @@ var golden = []struct {
 		name: "testdata/input-VA.flac",
 		blocks: []*meta.Block{
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, Length: 34},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x1, RawType: 0, Length: 34},
 				Body:   &meta.StreamInfo{BlockSizeMin: 0x1200, BlockSizeMax: 0x1200, FrameSizeMin: 0xe, FrameSizeMax: 0x10, SampleRate: 0xac44, ChannelCount: 0x2, BitsPerSample: 0x10, SampleCount: 0x16f8, MD5sum: [16]uint8{0x74, 0xff, 0xd4, 0x73, 0x7e, 0xb5, 0x48, 0x8d, 0x51, 0x2b, 0xe4, 0xaf, 0x58, 0x94, 0x33, 0x62}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x10, Length: 203},
+				Header: &meta.BlockHeader{IsLast: false, BlockType: 0x10, RawType: 4, Length: 203},
 				Body:   &meta.VorbisComment{Vendor: "reference libFLAC 1.1.3 20060805", Entries: []meta.VorbisEntry{meta.VorbisEntry{Name: "REPLAYGAIN_TRACK_PEAK", Value: "0.99996948"}, meta.VorbisEntry{Name: "REPLAYGAIN_TRACK_GAIN", Value: "-7.89 dB"}, meta.VorbisEntry{Name: "REPLAYGAIN_ALBUM_PEAK", Value: "0.99996948"}, meta.VorbisEntry{Name: "REPLAYGAIN_ALBUM_GAIN", Value: "-7.89 dB"}, meta.VorbisEntry{Name: "artist", Value: "1"}, meta.VorbisEntry{Name: "title", Value: "2"}}},
 			},
 			{
-				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x4, Length: 4},
+				Header: &meta.BlockHeader{IsLast: true, BlockType: 0x4, RawType: 2, Length: 4},
 				Body:   &meta.Application{ID: "fake", Data: []uint8{}},
 			},
 		},
@@ -250,12 +259,12 @@ func TestParseBlocks(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		if len(s.MetaBlocks) != len(g.blocks) {
-			t.Errorf("i=%d: invalid number of metadata blocks; expected %d, got %d.", i, len(g.blocks), len(s.MetaBlocks))
+		if len(s.Blocks) != len(g.blocks) {
+			t.Errorf("i=%d: invalid number of metadata blocks; expected %d, got %d.", i, len(g.blocks), len(s.Blocks))
 			continue
 		}
 
-		for j, got := range s.MetaBlocks {
+		for j, got := range s.Blocks {
 			want := g.blocks[j]
 			if !reflect.DeepEqual(got.Header, want.Header) {
 				t.Errorf("i=%d, j=%d: metadata block headers differ; expected %#v, got %#v.", i, j, want.Header, got.Header)
@@ -267,6 +276,111 @@ func TestParseBlocks(t *testing.T) {
 	}
 }
 
+func TestBlockReParse(t *testing.T) {
+	for i, g := range golden {
+		s, err := flac.Open(g.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+
+		// Only parse the StreamInfo block, skipping all other blocks.
+		err = s.ParseBlocks(meta.TypeStreamInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for j, got := range s.Blocks {
+			want := g.blocks[j]
+			if want.Header.BlockType&(meta.TypeStreamInfo|meta.TypeReserved) != 0 {
+				// The bodies of reserved blocks are not parsed by the golden
+				// tests.
+				continue
+			}
+			err = got.ReParse()
+			if err != nil {
+				t.Errorf("i=%d, j=%d: unexpected error; %v", i, j, err)
+				continue
+			}
+			if !reflect.DeepEqual(got.Body, want.Body) {
+				t.Errorf("i=%d, j=%d: metadata block bodies differ; expected %#v, got %#v.", i, j, want.Body, got.Body)
+			}
+		}
+	}
+
+	// Non-seekable readers.
+	block, err := meta.NewBlock(bytes.NewBuffer([]byte{0x01, 0x00, 0x00, 0x00}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := block.ReParse(); err == nil {
+		t.Errorf("expected error for non-seekable reader")
+	}
+
+	// Corrupt block body; StreamInfo with a min block size of 0, followed by a
+	// byte.
+	buf := append([]byte{0x00, 0x00, 0x00, 0x22}, make([]byte, 34+1)...)
+	r := bytes.NewReader(buf)
+	block, err = meta.NewBlock(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := block.Skip(); err != nil {
+		t.Fatal(err)
+	}
+	if err := block.ReParse(); err == nil {
+		t.Errorf("expected error for corrupt block body")
+	}
+	if r.Len() != 1 {
+		t.Errorf("invalid reader position after failed ReParse; expected 1 byte remaining, got %d", r.Len())
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	buf, err := ioutil.ReadFile("../testdata/59996.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Skip the "fLaC" signature and the StreamInfo metadata block, and truncate
+	// the VorbisComment metadata block at each possible position of its body.
+	buf = buf[4+4+34:]
+	const length = 202
+	for n := 0; n < length; n++ {
+		_, err := meta.ParseBlock(bytes.NewReader(buf[:4+n]))
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("n=%d: expected io.ErrUnexpectedEOF, got %v", n, err)
+		}
+	}
+}
+
+func TestParseOversizedLength(t *testing.T) {
+	// A Vorbis comment declaring a vendor string of 4 GiB, followed by 4 bytes
+	// of data. The declared length must not be allocated up front.
+	buf := []byte{0xF0, 0xFF, 0xFF, 0xFF, 'f', 'o', 'o', 0}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := meta.ParseVorbisComment(bytes.NewReader(buf))
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("invalid number of bytes allocated; expected <= %d, got %d", 1<<20, n)
+	}
+}
+
+func TestParseBlockHeaderInvalid(t *testing.T) {
+	// The first bytes of an audio frame; a frame sync code is parsed as the
+	// invalid block type 127.
+	_, err := meta.ParseBlockHeader(bytes.NewReader([]byte{0xFF, 0xF8, 0xC9, 0xAC}))
+	if !errors.Is(err, meta.ErrReservedInvalidBlockType) {
+		t.Errorf("expected meta.ErrReservedInvalidBlockType, got %v", err)
+	}
+	if !errors.Is(err, meta.ErrInvalidBlockType) {
+		t.Errorf("expected meta.ErrInvalidBlockType, got %v", err)
+	}
+}
+
 func TestParsePicture(t *testing.T) {
 	s, err := flac.Open("testdata/silence.flac")
 	if err != nil {
@@ -284,7 +398,7 @@ func TestParsePicture(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	for _, block := range s.MetaBlocks {
+	for _, block := range s.Blocks {
 		if block.Header.BlockType == meta.TypePicture {
 			pic := block.Body.(*meta.Picture)
 			got := pic.Data
@@ -295,3 +409,953 @@ func TestParsePicture(t *testing.T) {
 		}
 	}
 }
+
+func TestParsePictureMetadataOnly(t *testing.T) {
+	f, err := os.Open("testdata/silence.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	opts := &flac.Options{}
+	opts.PictureMetadataOnly = true
+	s, err := flac.ParseWithOptions(f, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := ioutil.ReadFile("testdata/silence.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pics := s.Pictures()
+	if len(pics) == 0 {
+		t.Fatal("no pictures found")
+	}
+	pic := pics[0]
+	if pic.Data != nil {
+		t.Errorf("picture data loaded; expected nil, got %d bytes", len(pic.Data))
+	}
+	if pic.DataLen() != len(want) {
+		t.Errorf("invalid picture data length; expected %d, got %d", len(want), pic.DataLen())
+	}
+	got, err := pic.LoadData(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("picture data differ; expected %v, got %v", want, got)
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	names := []string{"testdata/silence.flac"}
+	for _, g := range golden {
+		names = append(names, g.name)
+	}
+	for i, name := range names {
+		buf, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Skip the "fLaC" signature.
+		buf = buf[4:]
+
+		r := bytes.NewReader(buf)
+		for j := 0; ; j++ {
+			start := len(buf) - r.Len()
+			block, err := meta.ParseBlock(r)
+			if err != nil {
+				t.Fatalf("i=%d, j=%d: %v", i, j, err)
+			}
+			end := len(buf) - r.Len()
+			got := new(bytes.Buffer)
+			n, err := block.WriteTo(got)
+			if err != nil {
+				t.Errorf("i=%d, j=%d: %v", i, j, err)
+			} else {
+				want := buf[start:end]
+				if n != int64(len(want)) {
+					t.Errorf("i=%d, j=%d: invalid number of bytes written; expected %d, got %d", i, j, len(want), n)
+				}
+				if !bytes.Equal(got.Bytes(), want) {
+					t.Errorf("i=%d, j=%d: metadata blocks differ; expected %v, got %v", i, j, want, got.Bytes())
+				}
+			}
+			if block.Header.IsLast {
+				break
+			}
+		}
+	}
+}
+
+func TestVorbisCommentMarshal(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/input-VA.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The VorbisComment block is located directly after the signature (4 bytes)
+	// and the StreamInfo block (4+34 bytes).
+	r := bytes.NewReader(buf[4+4+34:])
+	block, err := meta.NewBlock(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Header.BlockType != meta.TypeVorbisComment {
+		t.Fatalf("invalid block type; expected %v, got %v", meta.TypeVorbisComment, block.Header.BlockType)
+	}
+	want := buf[4+4+34+4 : 4+4+34+4+block.Header.Length]
+	vc, err := meta.ParseVorbisComment(bytes.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := vc.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("vorbis comment bodies differ; expected %v, got %v", want, got)
+	}
+	w := new(bytes.Buffer)
+	n, err := vc.WriteTo(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(want)) {
+		t.Errorf("invalid number of bytes written; expected %d, got %d", len(want), n)
+	}
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("written vorbis comment bodies differ; expected %v, got %v", want, w.Bytes())
+	}
+
+	// Invalid UTF-8 values must be rejected.
+	vc.Entries[0].Value = "\xff"
+	_, err = vc.Marshal()
+	if err == nil {
+		t.Errorf("expected error for invalid UTF-8 value")
+	}
+	w.Reset()
+	_, err = vc.WriteTo(w)
+	if err == nil {
+		t.Errorf("expected error for invalid UTF-8 value")
+	}
+	if w.Len() != 0 {
+		t.Errorf("invalid number of bytes written for invalid Vorbis comment; expected 0, got %d", w.Len())
+	}
+}
+
+func TestStreamInfoDuration(t *testing.T) {
+	s, err := flac.ParseFile("../testdata/59996.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// 8192 samples at 44100 Hz.
+	if got, want := s.Info.Samples(), uint64(8192); got != want {
+		t.Errorf("invalid number of samples; expected %d, got %d", want, got)
+	}
+	want := 8192 * time.Second / 44100
+	if got := s.Info.Duration(); got != want {
+		t.Errorf("invalid duration; expected %v, got %v", want, got)
+	}
+
+	// Unknown number of samples.
+	si := &meta.StreamInfo{SampleRate: 44100}
+	if got := si.Duration(); got != 0 {
+		t.Errorf("invalid duration for unknown number of samples; expected 0, got %v", got)
+	}
+}
+
+func TestStreamInfoBitrate(t *testing.T) {
+	golden := []struct {
+		si         *meta.StreamInfo
+		audioBytes int64
+		want       int
+	}{
+		// 1 second at 44100 Hz.
+		{si: &meta.StreamInfo{SampleRate: 44100, SampleCount: 44100}, audioBytes: 176400, want: 1411200},
+		// 8192 samples at 44100 Hz; 35411 * 8 * 44100 / 8192 = 1525024.51...
+		{si: &meta.StreamInfo{SampleRate: 44100, SampleCount: 8192}, audioBytes: 35411, want: 1525025},
+		// Largest number of samples.
+		{si: &meta.StreamInfo{SampleRate: 655350, SampleCount: 1<<36 - 1}, audioBytes: 1 << 40, want: 83884800},
+		// Unknown number of samples.
+		{si: &meta.StreamInfo{SampleRate: 44100}, audioBytes: 176400, want: 0},
+	}
+	for i, g := range golden {
+		if got := g.si.Bitrate(g.audioBytes); got != g.want {
+			t.Errorf("i=%d: invalid bitrate; expected %d, got %d", i, g.want, got)
+		}
+	}
+}
+
+func TestStreamInfoChannelLayout(t *testing.T) {
+	golden := []struct {
+		channels uint8
+		want     []string
+		// Default WAVEFORMATEXTENSIBLE channel mask of libFLAC, or 0 if the
+		// labels differ from those of SpeakerNames.
+		mask uint32
+	}{
+		{channels: 0, want: nil},
+		{channels: 1, want: []string{"Mono"}},
+		{channels: 2, want: []string{"L", "R"}, mask: 0x3},
+		{channels: 3, want: []string{"L", "R", "C"}, mask: 0x7},
+		{channels: 4, want: []string{"L", "R", "Lb", "Rb"}, mask: 0x33},
+		{channels: 5, want: []string{"L", "R", "C", "Lb", "Rb"}, mask: 0x37},
+		{channels: 6, want: []string{"L", "R", "C", "Lfe", "Lb", "Rb"}, mask: 0x3F},
+		{channels: 7, want: []string{"L", "R", "C", "Lfe", "Cs", "Ls", "Rs"}, mask: 0x70F},
+		{channels: 8, want: []string{"L", "R", "C", "Lfe", "Lb", "Rb", "Ls", "Rs"}, mask: 0x63F},
+		{channels: 9, want: nil},
+	}
+	for _, g := range golden {
+		si := &meta.StreamInfo{ChannelCount: g.channels}
+		got := si.ChannelLayout()
+		if !reflect.DeepEqual(got, g.want) {
+			t.Errorf("channels=%d: invalid channel layout; expected %v, got %v", g.channels, g.want, got)
+		}
+		if g.mask == 0 {
+			continue
+		}
+		if names := meta.SpeakerNames(g.mask); !reflect.DeepEqual(got, names) {
+			t.Errorf("channels=%d: channel layout differs from speaker names of channel mask 0x%X; expected %v, got %v", g.channels, g.mask, names, got)
+		}
+	}
+}
+
+func TestBuildSeekTable(t *testing.T) {
+	offsetFn := func(sample uint64) (uint64, uint16) {
+		return sample / 4096 * 100, 4096
+	}
+	st, err := meta.BuildSeekTable(10000, 4096, offsetFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []meta.SeekPoint{
+		{SampleNum: 0, Offset: 0, SampleCount: 4096},
+		{SampleNum: 4096, Offset: 100, SampleCount: 4096},
+		{SampleNum: 8192, Offset: 200, SampleCount: 4096},
+	}
+	if !reflect.DeepEqual(st.Points, want) {
+		t.Errorf("invalid seek points; expected %v, got %v", want, st.Points)
+	}
+
+	// Seek tables which would not fit in a metadata block are rejected before
+	// any seek point is computed.
+	called := false
+	tooMany := func(sample uint64) (uint64, uint16) {
+		called = true
+		return 0, 1
+	}
+	if _, err := meta.BuildSeekTable(1<<40, 1, tooMany); err == nil {
+		t.Errorf("expected error for too many seek points")
+	}
+	if called {
+		t.Errorf("offset function called for seek table with too many seek points")
+	}
+	// 932067 seek points (0xFFFFFF / 18) is the maximum.
+	if _, err := meta.BuildSeekTable(932067, 1, tooMany); err != nil {
+		t.Errorf("unable to build seek table with the maximum number of seek points; %v", err)
+	}
+	if _, err := meta.BuildSeekTable(932068, 1, tooMany); err == nil {
+		t.Errorf("expected error for 932068 seek points")
+	}
+}
+
+func TestSeekTableInsert(t *testing.T) {
+	placeholder := meta.SeekPoint{SampleNum: meta.PlaceholderPoint}
+	st := &meta.SeekTable{
+		Points: []meta.SeekPoint{
+			{SampleNum: 4096, Offset: 100, SampleCount: 4096},
+			{SampleNum: 12288, Offset: 300, SampleCount: 4096},
+			placeholder,
+		},
+	}
+	// Before, between and after the existing seek points.
+	st.Insert(meta.SeekPoint{SampleNum: 0, Offset: 0, SampleCount: 4096})
+	st.Insert(meta.SeekPoint{SampleNum: 8192, Offset: 200, SampleCount: 4096})
+	st.Insert(meta.SeekPoint{SampleNum: 16384, Offset: 400, SampleCount: 4096})
+	// Duplicate sample number.
+	st.Insert(meta.SeekPoint{SampleNum: 8192, Offset: 250, SampleCount: 4096})
+	want := []meta.SeekPoint{
+		{SampleNum: 0, Offset: 0, SampleCount: 4096},
+		{SampleNum: 4096, Offset: 100, SampleCount: 4096},
+		{SampleNum: 8192, Offset: 200, SampleCount: 4096},
+		{SampleNum: 8192, Offset: 250, SampleCount: 4096},
+		{SampleNum: 12288, Offset: 300, SampleCount: 4096},
+		{SampleNum: 16384, Offset: 400, SampleCount: 4096},
+		placeholder,
+	}
+	if !reflect.DeepEqual(st.Points, want) {
+		t.Errorf("seek points differ after insert; expected %v, got %v", want, st.Points)
+	}
+
+	st.Dedup()
+	want = append(want[:3], want[4:]...)
+	if !reflect.DeepEqual(st.Points, want) {
+		t.Errorf("seek points differ after dedup; expected %v, got %v", want, st.Points)
+	}
+	if _, err := st.Marshal(); err != nil {
+		t.Errorf("unable to marshal seek table; %v", err)
+	}
+	if n := st.Placeholders(); n != 1 {
+		t.Errorf("invalid number of placeholder points; expected 1, got %d", n)
+	}
+	if point, ok := st.Search(meta.PlaceholderPoint); !ok || point.SampleNum != 16384 {
+		t.Errorf("invalid seek point; expected sample number 16384, got %d (found: %t)", point.SampleNum, ok)
+	}
+
+	// Non-placeholder point after a placeholder point.
+	st.Points = append(st.Points, meta.SeekPoint{SampleNum: 20480, Offset: 500, SampleCount: 4096})
+	if _, err := st.Marshal(); err == nil {
+		t.Errorf("expected error for seek point after placeholder point")
+	}
+}
+
+func TestSanitizeUTF8(t *testing.T) {
+	// Vorbis comment with the Latin-1 encoded value "Café".
+	raw := []byte{
+		0x04, 0x00, 0x00, 0x16, // block header
+		0x00, 0x00, 0x00, 0x00, // vendor length
+		0x01, 0x00, 0x00, 0x00, // comment count
+		0x0A, 0x00, 0x00, 0x00, // vector length
+		'T', 'I', 'T', 'L', 'E', '=', 'C', 'a', 'f', 0xE9,
+	}
+
+	golden := []struct {
+		sanitize bool
+		want     string
+	}{
+		{sanitize: false, want: "Caf\xE9"},
+		{sanitize: true, want: "Caf\uFFFD"},
+	}
+	for _, g := range golden {
+		block, err := meta.NewBlock(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var warnings []string
+		opts := &meta.Options{
+			Warn:         func(msg string) { warnings = append(warnings, msg) },
+			SanitizeUTF8: g.sanitize,
+		}
+		err = block.ParseOptions(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vc := block.Body.(*meta.VorbisComment)
+		if got := vc.Entries[0].Value; got != g.want {
+			t.Errorf("sanitize=%t: invalid value; expected %q, got %q", g.sanitize, g.want, got)
+		}
+		if len(warnings) != 1 {
+			t.Errorf("sanitize=%t: invalid number of warnings; expected 1, got %d", g.sanitize, len(warnings))
+		}
+	}
+}
+
+func TestVorbisCommentNoSeparator(t *testing.T) {
+	// Vorbis comment with a comment vector lacking a '=' separator.
+	var want []byte
+	want = binary.LittleEndian.AppendUint32(want, 3)
+	want = append(want, "foo"...)
+	want = binary.LittleEndian.AppendUint32(want, 3)
+	for _, vector := range []string{"TITLE=bar", "garbage", "EMPTY="} {
+		want = binary.LittleEndian.AppendUint32(want, uint32(len(vector)))
+		want = append(want, vector...)
+	}
+	vc, err := meta.ParseVorbisComment(bytes.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vc.Entries) != 3 {
+		t.Fatalf("invalid number of entries; expected 3, got %d", len(vc.Entries))
+	}
+	if entry := vc.Entries[1]; !entry.NoSeparator || entry.Name != "garbage" {
+		t.Errorf("invalid entry without separator; got %+v", entry)
+	}
+	if _, ok := vc.Get("garbage"); ok {
+		t.Errorf("entry without separator returned by Get")
+	}
+	if values := vc.GetAll("garbage"); len(values) != 0 {
+		t.Errorf("entry without separator returned by GetAll; got %q", values)
+	}
+	if value, ok := vc.Get("empty"); !ok || value != "" {
+		t.Errorf("invalid empty value; expected \"\" (present), got %q (present: %v)", value, ok)
+	}
+
+	// Entries without a separator are written back unchanged.
+	got, err := vc.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("vorbis comment bodies differ; expected %q, got %q", want, got)
+	}
+	w := new(bytes.Buffer)
+	if _, err := vc.WriteTo(w); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("written vorbis comment bodies differ; expected %q, got %q", want, w.Bytes())
+	}
+}
+
+func TestVorbisCommentVendor(t *testing.T) {
+	for _, vendor := range []string{"", "reference libFLAC 1.3.2 20170101", " padded vendor "} {
+		vc := &meta.VorbisComment{Vendor: vendor}
+		buf, err := vc.Marshal()
+		if err != nil {
+			t.Errorf("vendor %q: %v", vendor, err)
+			continue
+		}
+		got, err := meta.ParseVorbisComment(bytes.NewReader(buf))
+		if err != nil {
+			t.Errorf("vendor %q: %v", vendor, err)
+			continue
+		}
+		if got.Vendor != vendor {
+			t.Errorf("vendor string differs; expected %q, got %q", vendor, got.Vendor)
+		}
+	}
+}
+
+func TestVorbisCommentLyrics(t *testing.T) {
+	vc := &meta.VorbisComment{
+		Entries: []meta.VorbisEntry{
+			{Name: "TITLE", Value: "foo"},
+			{Name: "unsyncedlyrics", Value: "old"},
+		},
+	}
+	if got, ok := vc.Lyrics(); !ok || got != "old" {
+		t.Errorf("invalid lyrics; expected %q, got %q (present: %v)", "old", got, ok)
+	}
+
+	const want = "First line\nSecond line = with equals sign\r\n\n\tLast line\n"
+	vc.SetLyrics(want)
+	if n := len(vc.GetAll("UNSYNCEDLYRICS")); n != 0 {
+		t.Errorf("invalid number of UNSYNCEDLYRICS entries after SetLyrics; expected 0, got %d", n)
+	}
+	buf, err := vc.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vc, err = meta.ParseVorbisComment(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := vc.Lyrics()
+	if !ok {
+		t.Fatal("lyrics not present after round-trip")
+	}
+	if got != want {
+		t.Errorf("lyrics differ after round-trip; expected %q, got %q", want, got)
+	}
+	if n := len(vc.Entries); n != 2 {
+		t.Errorf("invalid number of entries; expected 2, got %d", n)
+	}
+
+	if _, ok := (&meta.VorbisComment{}).Lyrics(); ok {
+		t.Errorf("lyrics present in empty Vorbis comment")
+	}
+}
+
+func TestCueSheetIsCD(t *testing.T) {
+	cs := &meta.CueSheet{
+		MCN:               "1234567890123",
+		LeadInSampleCount: 88200,
+		IsCompactDisc:     true,
+		TrackCount:        1,
+		Tracks:            []meta.CueSheetTrack{{Offset: 0, TrackNum: 170}},
+	}
+	buf, err := cs.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The is_compact_disc flag follows the 128 byte media catalog number and
+	// the 8 byte lead-in sample count.
+	const flagOffset = 128 + 8
+	if buf[flagOffset] != 0x80 {
+		t.Errorf("invalid is_compact_disc byte; expected 0x80, got 0x%02X", buf[flagOffset])
+	}
+	got, err := meta.ParseCueSheet(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.IsCD() {
+		t.Errorf("expected CD-DA cue sheet")
+	}
+	if got.LeadInSamples() != 88200 {
+		t.Errorf("invalid lead-in; expected 88200, got %d", got.LeadInSamples())
+	}
+	if got.MCN != cs.MCN {
+		t.Errorf("invalid media catalog number; expected %q, got %q", cs.MCN, got.MCN)
+	}
+
+	// Reserved bits following the is_compact_disc flag must be 0.
+	buf[flagOffset] |= 0x01
+	if _, err := meta.ParseCueSheet(bytes.NewReader(buf)); err == nil {
+		t.Errorf("expected error for reserved bits set")
+	}
+
+	// Non CD-DA cue sheet without lead-in.
+	cs.IsCompactDisc = false
+	cs.LeadInSampleCount = 0
+	cs.Tracks[0].TrackNum = 255
+	buf, err = cs.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = meta.ParseCueSheet(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.IsCD() || got.LeadInSamples() != 0 {
+		t.Errorf("invalid non CD-DA cue sheet; expected is-CD false and lead-in 0, got %v and %d", got.IsCD(), got.LeadInSamples())
+	}
+}
+
+func TestParseCueText(t *testing.T) {
+	const cdText = `CATALOG 1234567890123
+FILE "audio.wav" WAVE
+  TRACK 01 AUDIO
+    FLAGS PRE
+    ISRC USRC17607839
+    INDEX 01 00:00:00
+    INDEX 02 00:00:05
+  TRACK 02 AUDIO
+    INDEX 01 00:02:00
+`
+	cdWant := &meta.CueSheet{
+		MCN:               "1234567890123",
+		LeadInSampleCount: 88200,
+		IsCompactDisc:     true,
+		TrackCount:        3,
+		Tracks: []meta.CueSheetTrack{
+			{Offset: 0, TrackNum: 1, ISRC: "USRC17607839", IsAudio: true, HasPreEmphasis: true, TrackIndexCount: 2, TrackIndexes: []meta.CueSheetTrackIndex{{Offset: 0, IndexPointNum: 1}, {Offset: 5 * 588, IndexPointNum: 2}}},
+			{Offset: 150 * 588, TrackNum: 2, IsAudio: true, TrackIndexCount: 1, TrackIndexes: []meta.CueSheetTrackIndex{{Offset: 0, IndexPointNum: 1}}},
+			// Lead-out track at the total number of samples.
+			{Offset: 225 * 588, TrackNum: 170, IsAudio: true},
+		},
+	}
+	// Non CD-DA cue sheet of a 44.1kHz stream, whose length is not a whole
+	// number of CD sectors.
+	nonCDWant := &meta.CueSheet{
+		MCN:        "1234567890123",
+		TrackCount: 3,
+		Tracks: []meta.CueSheetTrack{
+			cdWant.Tracks[0],
+			cdWant.Tracks[1],
+			{Offset: 225*588 + 1, TrackNum: 255, IsAudio: true},
+		},
+	}
+	const text = "FILE \"audio.flac\" WAVE\nTRACK 01 AUDIO\nINDEX 01 00:00:00\nTRACK 02 AUDIO\nINDEX 00 00:01:00\nINDEX 01 00:01:15\n"
+	golden := []struct {
+		text        string
+		sampleRate  uint32
+		sampleCount uint64
+		isCD        bool
+		leadIn      uint64
+		want        *meta.CueSheet
+	}{
+		// CD-DA cue sheet.
+		{text: cdText, sampleRate: 44100, sampleCount: 225 * 588, isCD: true, leadIn: 88200, want: cdWant},
+		// Lead-out track of CD-DA cue sheet not evenly divisible by 588 samples.
+		{text: cdText, sampleRate: 44100, sampleCount: 225*588 + 1, isCD: true, leadIn: 88200},
+		// 588 sample alignment is only required for CD-DA cue sheets.
+		{text: cdText, sampleRate: 44100, sampleCount: 225*588 + 1, want: nonCDWant},
+		// CD-DA cue sheet of a 48kHz stream.
+		{text: cdText, sampleRate: 48000, sampleCount: 225 * 588, isCD: true, leadIn: 88200},
+		// Lead-in of non CD-DA cue sheet.
+		{text: cdText, sampleRate: 44100, sampleCount: 225 * 588, leadIn: 88200},
+		// Non CD-DA cue sheet.
+		{
+			text:        text,
+			sampleRate:  48000,
+			sampleCount: 96000,
+			want: &meta.CueSheet{
+				TrackCount: 3,
+				Tracks: []meta.CueSheetTrack{
+					{Offset: 0, TrackNum: 1, IsAudio: true, TrackIndexCount: 1, TrackIndexes: []meta.CueSheetTrackIndex{{Offset: 0, IndexPointNum: 1}}},
+					{Offset: 48000, TrackNum: 2, IsAudio: true, TrackIndexCount: 2, TrackIndexes: []meta.CueSheetTrackIndex{{Offset: 0, IndexPointNum: 0}, {Offset: 9600, IndexPointNum: 1}}},
+					{Offset: 96000, TrackNum: 255, IsAudio: true},
+				},
+			},
+		},
+		// Missing total number of samples.
+		{text: text, sampleRate: 48000},
+		// Index point past the end of the stream.
+		{text: text, sampleRate: 48000, sampleCount: 57600},
+		// INDEX command before the first TRACK command.
+		{text: "FILE \"audio.wav\" WAVE\nINDEX 01 00:00:00\nTRACK 01 AUDIO\n", sampleRate: 44100, sampleCount: 44100},
+		// Multiple FILE commands.
+		{text: "FILE \"a.wav\" WAVE\nTRACK 01 AUDIO\nINDEX 01 00:00:00\nFILE \"b.wav\" WAVE\nTRACK 02 AUDIO\nINDEX 01 00:00:00\n", sampleRate: 44100, sampleCount: 44100},
+		// Invalid timecode.
+		{text: "TRACK 01 AUDIO\nINDEX 01 00:60:00\n", sampleRate: 44100, sampleCount: 44100},
+	}
+	for i, g := range golden {
+		got, err := meta.ParseCueText(bytes.NewReader([]byte(g.text)), g.sampleRate, g.sampleCount, g.isCD, g.leadIn)
+		if g.want == nil {
+			if err == nil {
+				t.Errorf("i=%d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("i=%d: unable to parse cue text; %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, g.want) {
+			t.Errorf("i=%d: cue sheet mismatch; expected %#v, got %#v", i, g.want, got)
+		}
+	}
+
+	// Round trip through WriteCue.
+	buf := new(bytes.Buffer)
+	if err := cdWant.WriteCue(buf, "audio.wav", 44100); err != nil {
+		t.Fatal(err)
+	}
+	got, err := meta.ParseCueText(buf, 44100, 225*588, true, 88200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, cdWant) {
+		t.Errorf("cue sheet mismatch after WriteCue round trip; expected %#v, got %#v", cdWant, got)
+	}
+
+	// Audio filenames are written verbatim, without Go escape sequences.
+	buf.Reset()
+	if err := cdWant.WriteCue(buf, `C:\Music\a b.wav`, 44100); err != nil {
+		t.Fatal(err)
+	}
+	if want := "FILE \"C:\\Music\\a b.wav\" WAVE\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("missing FILE command %q; got %q", want, buf.String())
+	}
+	for _, name := range []string{`a"b.wav`, "a\nb.wav", "a\rb.wav"} {
+		if err := cdWant.WriteCue(ioutil.Discard, name, 44100); err == nil {
+			t.Errorf("expected error for audio filename %q", name)
+		}
+	}
+}
+
+func TestCueSheetTrackStartSample(t *testing.T) {
+	golden := []struct {
+		track     meta.CueSheetTrack
+		start     uint64
+		ok        bool
+		pregapLen uint64
+	}{
+		// Track without pre-gap.
+		{
+			track: meta.CueSheetTrack{Offset: 588 * 100, TrackNum: 1, TrackIndexes: []meta.CueSheetTrackIndex{{Offset: 0, IndexPointNum: 1}, {Offset: 588 * 20, IndexPointNum: 2}}},
+			start: 588 * 100,
+			ok:    true,
+		},
+		// Track with a pre-gap of 2 seconds.
+		{
+			track:     meta.CueSheetTrack{Offset: 588 * 100, TrackNum: 2, TrackIndexes: []meta.CueSheetTrackIndex{{Offset: 0, IndexPointNum: 0}, {Offset: 588 * 150, IndexPointNum: 1}}},
+			start:     588 * 250,
+			ok:        true,
+			pregapLen: 588 * 150,
+		},
+		// Lead-out track.
+		{
+			track: meta.CueSheetTrack{Offset: 588 * 1000, TrackNum: 170},
+		},
+	}
+	for _, g := range golden {
+		start, ok := g.track.StartSample()
+		if start != g.start || ok != g.ok {
+			t.Errorf("track %d: invalid start sample; expected %d (%v), got %d (%v)", g.track.TrackNum, g.start, g.ok, start, ok)
+		}
+		if got := g.track.PregapSamples(); got != g.pregapLen {
+			t.Errorf("track %d: invalid pre-gap length; expected %d, got %d", g.track.TrackNum, g.pregapLen, got)
+		}
+	}
+}
+
+func TestCueSheetTrackRanges(t *testing.T) {
+	s, err := flac.ParseFile("testdata/input-SCPAP.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	block, ok := s.FindBlock(meta.TypeCueSheet)
+	if !ok {
+		t.Fatal("missing CueSheet block")
+	}
+	cs := block.Body.(*meta.CueSheet)
+	want := []meta.SampleRange{
+		{Start: 0x0, End: 0xb7c, TrackNumber: 1},
+		{Start: 0xb7c, End: 0x16f8, TrackNumber: 2},
+	}
+	got := cs.TrackRanges(s.Info.SampleCount)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid track ranges; expected %v, got %v", want, got)
+	}
+
+	// Cue sheet without lead-out track.
+	cs.Tracks = cs.Tracks[:len(cs.Tracks)-1]
+	want[1].End = 0x2000
+	got = cs.TrackRanges(0x2000)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid track ranges without lead-out track; expected %v, got %v", want, got)
+	}
+}
+
+func TestBlockFingerprint(t *testing.T) {
+	parse := func() *flac.Stream {
+		s, err := flac.ParseFile("testdata/input-SCVPAP.flac")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		return s
+	}
+	s1, s2 := parse(), parse()
+	for i, block := range s1.Blocks {
+		fp := block.Fingerprint()
+		if fp == 0 {
+			t.Errorf("block %d: invalid fingerprint 0", i)
+			continue
+		}
+		if got := s2.Blocks[i].Fingerprint(); got != fp {
+			t.Errorf("block %d: fingerprints of identical blocks differ; expected 0x%016X, got 0x%016X", i, fp, got)
+		}
+
+		// The IsLast flag does not affect the fingerprint.
+		clone := block.Clone()
+		clone.Header.IsLast = !clone.Header.IsLast
+		if got := clone.Fingerprint(); got != fp {
+			t.Errorf("block %d: fingerprint affected by IsLast flag; expected 0x%016X, got 0x%016X", i, fp, got)
+		}
+	}
+
+	block, ok := s1.FindBlock(meta.TypeVorbisComment)
+	if !ok {
+		t.Fatal("missing VorbisComment block")
+	}
+	fp := block.Fingerprint()
+	block.Body.(*meta.VorbisComment).Vendor += "x"
+	if block.Fingerprint() == fp {
+		t.Errorf("fingerprint of modified block unchanged")
+	}
+}
+
+func TestParseTolerateUnknown(t *testing.T) {
+	// Reserved block type 120, with a registered block parser of 4 byte
+	// bodies.
+	meta.RegisterBlockParser(120, func(r io.Reader, length int) (interface{}, error) {
+		var x uint32
+		if err := binary.Read(r, binary.BigEndian, &x); err != nil {
+			return nil, err
+		}
+		if length != 4 {
+			return nil, fmt.Errorf("invalid block body length; expected 4, got %d", length)
+		}
+		return x, nil
+	})
+	// Metadata block header and 4 byte body.
+	valid := []byte{0x78, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x2A}
+	// Metadata block header and 3 byte body.
+	invalid := []byte{0x78, 0x00, 0x00, 0x03, 'f', 'o', 'o'}
+
+	var warnings []string
+	opts := &meta.Options{
+		TolerateUnknown: true,
+		Warn:            func(msg string) { warnings = append(warnings, msg) },
+	}
+	golden := []struct {
+		buf  []byte
+		opts *meta.Options
+		want interface{}
+	}{
+		{buf: valid, want: uint32(42)},
+		{buf: valid, opts: opts, want: uint32(42)},
+		// The error of the block parser is returned.
+		{buf: invalid},
+		// The raw bytes are stored when the block parser fails.
+		{buf: invalid, opts: opts, want: []byte("foo")},
+	}
+	for i, g := range golden {
+		warnings = nil
+		// Follow the block by a byte, to verify that the entire block body is
+		// consumed.
+		r := bytes.NewReader(append(g.buf, 0xFF))
+		block, err := meta.NewBlock(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = block.ParseOptions(g.opts)
+		if g.want == nil {
+			if err == nil {
+				t.Errorf("i=%d: expected error for invalid block body", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("i=%d: unexpected error; %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(block.Body, g.want) {
+			t.Errorf("i=%d: invalid block body; expected %#v, got %#v", i, g.want, block.Body)
+		}
+		if r.Len() != 1 {
+			t.Errorf("i=%d: invalid reader position; expected 1 byte remaining, got %d", i, r.Len())
+		}
+		wantWarnings := 0
+		if _, ok := g.want.([]byte); ok {
+			wantWarnings = 1
+		}
+		if len(warnings) != wantWarnings {
+			t.Errorf("i=%d: invalid number of warnings; expected %d, got %d", i, wantWarnings, len(warnings))
+		}
+	}
+}
+
+func BenchmarkParseBlocks(b *testing.B) {
+	buf, err := ioutil.ReadFile("testdata/input-VA.flac")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Skip the "fLaC" signature.
+		r := bytes.NewReader(buf[4:])
+		for {
+			block, err := meta.ParseBlock(r)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if block.Header.IsLast {
+				break
+			}
+		}
+	}
+}
+
+// parseBlockHeaderBits parses a metadata block header using a bit reader, as
+// done by previous versions of meta.ParseBlockHeader. It is used as a baseline
+// by BenchmarkParseBlockHeader.
+func parseBlockHeaderBits(r io.Reader) (h *meta.BlockHeader, err error) {
+	br := bit.NewReader(r)
+	fields, err := br.ReadFields(1, 7, 24)
+	if err != nil {
+		return nil, err
+	}
+	h = &meta.BlockHeader{IsLast: fields[0] != 0, RawType: uint8(fields[1]), Length: int(fields[2])}
+	return h, nil
+}
+
+func BenchmarkParseBlockHeader(b *testing.B) {
+	// Collect the metadata blocks of the golden files.
+	var bufs [][]byte
+	for _, g := range golden {
+		buf, err := ioutil.ReadFile(g.name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		bufs = append(bufs, buf[4:])
+	}
+	benchs := []struct {
+		name  string
+		parse func(r io.Reader) (*meta.BlockHeader, error)
+	}{
+		{name: "bit.Reader", parse: parseBlockHeaderBits},
+		{name: "io.ReadFull", parse: meta.ParseBlockHeader},
+	}
+	for _, bench := range benchs {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, buf := range bufs {
+					r := bytes.NewReader(buf)
+					for {
+						h, err := bench.parse(r)
+						if err != nil {
+							b.Fatal(err)
+						}
+						if h.IsLast {
+							break
+						}
+						r.Seek(int64(h.Length), io.SeekCurrent)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParseBodies(b *testing.B) {
+	// Collect the SeekTable and Picture metadata block bodies of the golden
+	// files and testdata/silence.flac.
+	paths := []string{"testdata/silence.flac"}
+	for _, g := range golden {
+		paths = append(paths, g.name)
+	}
+	var seekTables, pictures [][]byte
+	for _, path := range paths {
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		r := bytes.NewReader(buf[4:])
+		for {
+			h, err := meta.ParseBlockHeader(r)
+			if err != nil {
+				b.Fatal(err)
+			}
+			body := make([]byte, h.Length)
+			if _, err := io.ReadFull(r, body); err != nil {
+				b.Fatal(err)
+			}
+			switch h.BlockType {
+			case meta.TypeSeekTable:
+				seekTables = append(seekTables, body)
+			case meta.TypePicture:
+				pictures = append(pictures, body)
+			}
+			if h.IsLast {
+				break
+			}
+		}
+	}
+	benchs := []struct {
+		name   string
+		bodies [][]byte
+		parse  func(r io.Reader) error
+	}{
+		{
+			name:   "SeekTable",
+			bodies: seekTables,
+			parse: func(r io.Reader) error {
+				_, err := meta.ParseSeekTable(r)
+				return err
+			},
+		},
+		{
+			name:   "Picture",
+			bodies: pictures,
+			parse: func(r io.Reader) error {
+				_, err := meta.ParsePicture(r)
+				return err
+			},
+		},
+	}
+	for _, bench := range benchs {
+		if len(bench.bodies) == 0 {
+			b.Fatalf("no %s metadata blocks in test files", bench.name)
+		}
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, body := range bench.bodies {
+					if err := bench.parse(bytes.NewReader(body)); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package meta
+
+import "fmt"
+
+// Options specifies optional behaviour of Block.ParseOptions. A nil *Options
+// is equivalent to the zero value, which parses metadata blocks the same way as
+// Block.Parse.
+type Options struct {
+	// Warn, if non-nil, is called with a description of each non-fatal problem
+	// encountered while parsing a metadata block body; e.g. a malformed ISRC
+	// of a cue sheet track. Such problems are otherwise silently accepted.
+	// Padding blocks containing non-zero bytes are reported through Warn rather
+	// than failing with ErrInvalidPadding.
+	Warn func(msg string)
+	// PictureMetadataOnly specifies that the picture data of Picture metadata
+	// blocks should be skipped rather than read into Picture.Data, to reduce
+	// memory usage when only the picture metadata is of interest. The length
+	// and offset of the picture data are recorded, so that it may be loaded
+	// later using Picture.LoadData.
+	PictureMetadataOnly bool
+	// SanitizeUTF8 specifies that invalid UTF-8 sequences in the vendor string
+	// and entry values of Vorbis comments should be replaced with the Unicode
+	// replacement character U+FFFD; e.g. for values stored in Latin-1 by
+	// non-conforming encoders. Invalid UTF-8 is reported through Warn if set,
+	// regardless of SanitizeUTF8. By default, the bytes are preserved exactly.
+	SanitizeUTF8 bool
+	// TolerateUnknown specifies that the bodies of reserved metadata blocks
+	// which their registered block parser fails to parse should be read as raw
+	// []byte bodies, rather than failing with the error of the block parser;
+	// e.g. for revisions of a block format unknown to the block parser. Such
+	// blocks are reported through Warn if set. Note that the bodies of reserved
+	// blocks without a registered block parser are always read as raw bytes.
+	TolerateUnknown bool
+}
+
+// warnf reports a non-fatal problem through opts.Warn, if set.
+func (opts *Options) warnf(format string, a ...interface{}) {
+	if opts == nil || opts.Warn == nil {
+		return
+	}
+	opts.Warn(fmt.Sprintf(format, a...))
+}
@@ -2,9 +2,14 @@ package meta
 
 import (
 	"errors"
+	"fmt"
 	"io"
 )
 
+// ErrInvalidPadding is returned when the body of a padding metadata block
+// contains non-zero bytes.
+var ErrInvalidPadding = errors.New("meta: invalid padding; must contain only zeroes")
+
 // VerifyPadding verifies that the padding metadata block only contains 0 bits.
 // The provided io.Reader should limit the amount of data that can be read to
 // header.Length bytes.
@@ -20,12 +25,41 @@ func VerifyPadding(r io.Reader) (err error) {
 			return err
 		}
 		if !isAllZero(buf[:n]) {
-			return errors.New("meta.VerifyPadding: invalid padding; must contain only zeroes")
+			return fmt.Errorf("meta.VerifyPadding: %w", ErrInvalidPadding)
 		}
 	}
 	return nil
 }
 
+// maxPaddingLength is the maximum length in bytes of a padding metadata block
+// body, as limited by the 24-bit length of the metadata block header.
+const maxPaddingLength = 0xFFFFFF
+
+// NewPadding returns a new padding metadata block whose body consists of length
+// zero bytes. The length should be between 0 and 16777215 (2^24-1) bytes;
+// writing a padding block of any other length fails.
+func NewPadding(length int) *Block {
+	h := &BlockHeader{
+		BlockType: TypePadding,
+		RawType:   rawBlockType[TypePadding],
+		Length:    length,
+	}
+	return &Block{Header: h}
+}
+
+// SetPaddingLength sets the body length of the padding metadata block to n
+// bytes. When written, the block body consists of exactly n zero bytes.
+func (block *Block) SetPaddingLength(n int) error {
+	if block.Header.BlockType != TypePadding {
+		return fmt.Errorf("meta.Block.SetPaddingLength: invalid block type; expected %v, got %v", TypePadding, block.Header.BlockType)
+	}
+	if n < 0 || n > maxPaddingLength {
+		return fmt.Errorf("meta.Block.SetPaddingLength: invalid padding length; expected >= 0 and <= %d, got %d", maxPaddingLength, n)
+	}
+	block.Header.Length = n
+	return nil
+}
+
 // isAllZero returns true if the value of each byte in the provided slice is 0,
 // and false otherwise.
 func isAllZero(buf []byte) bool {
@@ -1,42 +1,28 @@
 package meta
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	// Register image formats commonly used for embedded pictures.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
 	"io/ioutil"
+	"unicode/utf8"
 )
 
 // A Picture metadata block stores pictures associated with the file, most
 // commonly cover art from CDs. There may be more than one Picture block in a
 // file.
 type Picture struct {
-	// The picture type according to the ID3v2 APIC frame:
-	//    0 - Other
-	//    1 - 32x32 pixels 'file icon' (PNG only)
-	//    2 - Other file icon
-	//    3 - Cover (front)
-	//    4 - Cover (back)
-	//    5 - Leaflet page
-	//    6 - Media (e.g. label side of CD)
-	//    7 - Lead artist/lead performer/soloist
-	//    8 - Artist/performer
-	//    9 - Conductor
-	//    10 - Band/Orchestra
-	//    11 - Composer
-	//    12 - Lyricist/text writer
-	//    13 - Recording Location
-	//    14 - During recording
-	//    15 - During performance
-	//    16 - Movie/video screen capture
-	//    17 - A bright coloured fish
-	//    18 - Illustration
-	//    19 - Band/artist logotype
-	//    20 - Publisher/Studio logotype
-	//
-	// Others are reserved and should not be used. There may only be one each of
-	// picture type 1 and 2 in a file.
-	Type uint32
+	// The picture type according to the ID3v2 APIC frame. There may only be one
+	// each of picture type 1 and 2 in a file.
+	Type PictureType
 	// The MIME type string, in printable ASCII characters 0x20-0x7e. The MIME
 	// type may also be `-->` to signify that the data part is a URL of the
 	// picture instead of the picture data itself.
@@ -52,10 +38,217 @@ type Picture struct {
 	// For indexed-color pictures (e.g. GIF), the number of colors used, or 0 for
 	// non-indexed pictures.
 	ColorCount uint32
-	// The binary picture data.
+	// The binary picture data; nil if the picture was parsed with
+	// Options.PictureMetadataOnly, in which case it may be loaded using
+	// LoadData.
 	Data []byte
+	// Length in bytes of the picture data not yet loaded; only set if parsed
+	// with Options.PictureMetadataOnly.
+	dataLen int
+	// Offset in bytes of the picture data within the underlying reader of the
+	// metadata block, or -1 if unknown.
+	dataOffset int64
 }
 
+// PictureType specifies the type of a picture, according to the ID3v2 APIC
+// frame.
+type PictureType uint32
+
+// Picture types. Others are reserved and should not be used.
+const (
+	PictureOther             PictureType = iota // 0 - Other
+	PictureFileIcon                             // 1 - 32x32 pixels 'file icon' (PNG only)
+	PictureOtherFileIcon                        // 2 - Other file icon
+	PictureFrontCover                           // 3 - Cover (front)
+	PictureBackCover                            // 4 - Cover (back)
+	PictureLeaflet                              // 5 - Leaflet page
+	PictureMedia                                // 6 - Media (e.g. label side of CD)
+	PictureLeadArtist                           // 7 - Lead artist/lead performer/soloist
+	PictureArtist                               // 8 - Artist/performer
+	PictureConductor                            // 9 - Conductor
+	PictureBand                                 // 10 - Band/Orchestra
+	PictureComposer                             // 11 - Composer
+	PictureLyricist                             // 12 - Lyricist/text writer
+	PictureRecordingLocation                    // 13 - Recording Location
+	PictureDuringRecording                      // 14 - During recording
+	PictureDuringPerformance                    // 15 - During performance
+	PictureScreenCapture                        // 16 - Movie/video screen capture
+	PictureFish                                 // 17 - A bright coloured fish
+	PictureIllustration                         // 18 - Illustration
+	PictureBandLogo                             // 19 - Band/artist logotype
+	PicturePublisherLogo                        // 20 - Publisher/Studio logotype
+)
+
+// pictureTypeName is a map from PictureType to name.
+var pictureTypeName = map[PictureType]string{
+	PictureOther:             "Other",
+	PictureFileIcon:          "32x32 pixels 'file icon' (PNG only)",
+	PictureOtherFileIcon:     "Other file icon",
+	PictureFrontCover:        "Cover (front)",
+	PictureBackCover:         "Cover (back)",
+	PictureLeaflet:           "Leaflet page",
+	PictureMedia:             "Media (e.g. label side of CD)",
+	PictureLeadArtist:        "Lead artist/lead performer/soloist",
+	PictureArtist:            "Artist/performer",
+	PictureConductor:         "Conductor",
+	PictureBand:              "Band/Orchestra",
+	PictureComposer:          "Composer",
+	PictureLyricist:          "Lyricist/text writer",
+	PictureRecordingLocation: "Recording Location",
+	PictureDuringRecording:   "During recording",
+	PictureDuringPerformance: "During performance",
+	PictureScreenCapture:     "Movie/video screen capture",
+	PictureFish:              "A bright coloured fish",
+	PictureIllustration:      "Illustration",
+	PictureBandLogo:          "Band/artist logotype",
+	PicturePublisherLogo:     "Publisher/Studio logotype",
+}
+
+func (t PictureType) String() string {
+	if s, ok := pictureTypeName[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("reserved picture type %d", uint32(t))
+}
+
+// ErrPictureURL is returned when attempting to decode a picture whose data is
+// a URL of the picture instead of the picture data itself; i.e. when its MIME
+// type is "-->".
+var ErrPictureURL = errors.New("meta: picture data is a URL")
+
+// Decode decodes the embedded picture data. It returns the decoded image and
+// the format name used during format registration (e.g. "jpeg" or "png"). An
+// error wrapping ErrPictureURL is returned if the data is a URL of the picture.
+func (pic *Picture) Decode() (img image.Image, format string, err error) {
+	if pic.MIME == "-->" {
+		return nil, "", fmt.Errorf("meta.Picture.Decode: %w; %q", ErrPictureURL, string(pic.Data))
+	}
+	if pic.Data == nil && pic.dataLen > 0 {
+		return nil, "", errors.New("meta.Picture.Decode: picture data not loaded; parsed with Options.PictureMetadataOnly")
+	}
+	return image.Decode(bytes.NewReader(pic.Data))
+}
+
+// Thumbnail decodes the embedded picture data and scales it down to fit within
+// maxWidth x maxHeight pixels, preserving its aspect ratio. Pictures which
+// already fit within the bounds are returned unscaled. Nearest-neighbor
+// sampling is used. An error wrapping ErrPictureURL is returned if the data is
+// a URL of the picture.
+func (pic *Picture) Thumbnail(maxWidth, maxHeight int) (image.Image, error) {
+	if maxWidth <= 0 || maxHeight <= 0 {
+		return nil, fmt.Errorf("meta.Picture.Thumbnail: invalid bounds; expected > 0, got %dx%d", maxWidth, maxHeight)
+	}
+	img, _, err := pic.Decode()
+	if err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= maxWidth && h <= maxHeight {
+		return img, nil
+	}
+
+	// Compute the largest dimensions which fit within the bounds; the scale
+	// factor is min(maxWidth/w, maxHeight/h).
+	dw, dh := maxWidth, h*maxWidth/w
+	if dh > maxHeight {
+		dw, dh = w*maxHeight/h, maxHeight
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		// Sample the center of each destination pixel.
+		sy := bounds.Min.Y + (2*y+1)*h/(2*dh)
+		for x := 0; x < dw; x++ {
+			sx := bounds.Min.X + (2*x+1)*w/(2*dw)
+			dst.Set(x, y, img.At(sx, sy))
+		}
+	}
+	return dst, nil
+}
+
+// NewPicture returns a new Picture metadata block with the provided picture
+// type, MIME type, description and picture data. The width, height, color depth
+// and color count of the picture are determined by decoding the header of the
+// picture data; the supported formats are GIF, JPEG and PNG. If mimeType is
+// empty, it is inferred from the picture format (e.g. "image/png"). If mimeType
+// is "-->", data is a URL of the picture and is stored as is.
+func NewPicture(pictureType PictureType, mimeType, description string, data []byte) (block *Block, err error) {
+	if pictureType > PicturePublisherLogo {
+		return nil, fmt.Errorf("meta.NewPicture: reserved picture type: %d", pictureType)
+	}
+	if !utf8.ValidString(description) {
+		return nil, fmt.Errorf("meta.NewPicture: invalid description %q; not valid UTF-8", description)
+	}
+	pic := &Picture{Type: pictureType, MIME: mimeType, Desc: description, Data: data}
+	if mimeType != "-->" {
+		conf, format, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("meta.NewPicture: unable to decode picture header; %v", err)
+		}
+		if pic.MIME == "" {
+			pic.MIME = "image/" + format
+		}
+		pic.Width = uint32(conf.Width)
+		pic.Height = uint32(conf.Height)
+		pic.ColorDepth, pic.ColorCount = colorInfo(conf.ColorModel)
+	}
+	// Marshal validates the MIME type.
+	buf, err := pic.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	h := &BlockHeader{
+		BlockType: TypePicture,
+		RawType:   rawBlockType[TypePicture],
+		Length:    len(buf),
+	}
+	return &Block{Header: h, Body: pic}, nil
+}
+
+// NewPictureFromFile returns a new Picture metadata block with the provided
+// picture type and the contents of the provided image file as picture data. The
+// MIME type is inferred from the picture format; see NewPicture.
+func NewPictureFromFile(pictureType PictureType, filePath string) (block *Block, err error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewPicture(pictureType, "", "", data)
+}
+
+// colorInfo returns the color depth in bits-per-pixel of the provided color
+// model, and the number of colors for indexed-color models.
+func colorInfo(model color.Model) (depth, count uint32) {
+	if p, ok := model.(color.Palette); ok {
+		// Palette entries are stored as RGB.
+		return 24, uint32(len(p))
+	}
+	switch model {
+	case color.GrayModel:
+		return 8, 0
+	case color.Gray16Model:
+		return 16, 0
+	case color.RGBAModel, color.NRGBAModel, color.CMYKModel:
+		return 32, 0
+	case color.RGBA64Model, color.NRGBA64Model:
+		return 64, 0
+	}
+	// color.YCbCrModel and others.
+	return 24, 0
+}
+
+// MaxPictureSize is the maximum length in bytes of picture data accepted by
+// ParsePicture. Pictures which declare a larger data length are rejected before
+// the data is read, to protect against corrupt or malicious files.
+var MaxPictureSize = 64 << 20 // 64 MiB
+
 // ParsePicture parses and returns a new Picture metadata block. The provided
 // io.Reader should limit the amount of data that can be read to header.Length
 // bytes.
@@ -78,88 +271,182 @@ type Picture struct {
 //
 // ref: http://flac.sourceforge.net/format.html#metadata_block_picture
 func ParsePicture(r io.Reader) (pic *Picture, err error) {
+	pic, dataLen, err := parsePictureMetadata(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Data.
+	if int64(dataLen) > int64(MaxPictureSize) {
+		return nil, fmt.Errorf("meta.ParsePicture: picture data too large; expected <= %d bytes, got %d", MaxPictureSize, dataLen)
+	}
+	pic.Data, err = ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(pic.Data) != int(dataLen) {
+		return nil, fmt.Errorf("meta.ParsePicture: invalid data length; expected %d, got %d", dataLen, len(pic.Data))
+	}
+
+	return pic, nil
+}
+
+// parsePictureMetadata parses the fields of a Picture metadata block up to and
+// including the data length, leaving r positioned at the start of the picture
+// data. It returns the parsed picture and the length of its picture data.
+func parsePictureMetadata(r io.Reader) (pic *Picture, dataLen uint32, err error) {
+	sc := getScratch()
+	defer sc.release()
+
 	// Type.
 	pic = new(Picture)
-	err = binary.Read(r, binary.BigEndian, &pic.Type)
+	x, err := sc.readUint32BE(r)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	if pic.Type > 20 {
-		return nil, fmt.Errorf("meta.ParsePicture: reserved picture type: %d", pic.Type)
+	pic.Type = PictureType(x)
+	if pic.Type > PicturePublisherLogo {
+		return nil, 0, fmt.Errorf("meta.ParsePicture: reserved picture type: %d", pic.Type)
 	}
 
 	// Mime length.
-	var mimeLen uint32
-	err = binary.Read(r, binary.BigEndian, &mimeLen)
+	mimeLen, err := sc.readUint32BE(r)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// Mime string.
-	buf, err := readBytes(r, int(mimeLen))
+	buf, err := sc.readBytes(r, int(mimeLen))
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	pic.MIME = getStringFromSZ(buf)
 	for _, r := range pic.MIME {
 		if r < 0x20 || r > 0x7E {
-			return nil, fmt.Errorf("meta.ParsePicture: invalid character in MIME type; expected >= 0x20 and <= 0x7E, got 0x%02X", r)
+			return nil, 0, fmt.Errorf("meta.ParsePicture: invalid character in MIME type; expected >= 0x20 and <= 0x7E, got 0x%02X", r)
 		}
 	}
 
 	// Desc length.
-	var descLen uint32
-	err = binary.Read(r, binary.BigEndian, &descLen)
+	descLen, err := sc.readUint32BE(r)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// Desc string.
-	buf, err = readBytes(r, int(descLen))
+	buf, err = sc.readBytes(r, int(descLen))
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	pic.Desc = getStringFromSZ(buf)
 
 	// Width.
-	err = binary.Read(r, binary.BigEndian, &pic.Width)
+	pic.Width, err = sc.readUint32BE(r)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// Height.
-	err = binary.Read(r, binary.BigEndian, &pic.Height)
+	pic.Height, err = sc.readUint32BE(r)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// ColorDepth.
-	err = binary.Read(r, binary.BigEndian, &pic.ColorDepth)
+	pic.ColorDepth, err = sc.readUint32BE(r)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// ColorCount.
-	err = binary.Read(r, binary.BigEndian, &pic.ColorCount)
+	pic.ColorCount, err = sc.readUint32BE(r)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// Data length.
-	var dataLen uint32
-	err = binary.Read(r, binary.BigEndian, &dataLen)
+	dataLen, err = sc.readUint32BE(r)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	// Data.
-	pic.Data, err = ioutil.ReadAll(r)
-	if err != nil {
-		return nil, err
+	return pic, dataLen, nil
+}
+
+// DataLen returns the length in bytes of the picture data. For pictures parsed
+// with Options.PictureMetadataOnly it is the length stored in the stream.
+func (pic *Picture) DataLen() int {
+	if pic.Data == nil {
+		return pic.dataLen
 	}
-	if len(pic.Data) != int(dataLen) {
-		return nil, fmt.Errorf("meta.ParsePicture: invalid data length; expected %d, got %d", dataLen, len(pic.Data))
+	return len(pic.Data)
+}
+
+// LoadData reads and returns the picture data of a picture parsed with
+// Options.PictureMetadataOnly, from the provided io.ReaderAt of the underlying
+// stream; e.g. the *os.File of a stream opened with flac.Open. The picture data
+// is returned directly if already present. The loaded data is not retained by
+// pic.
+func (pic *Picture) LoadData(ra io.ReaderAt) ([]byte, error) {
+	if pic.Data != nil || pic.dataLen == 0 {
+		return pic.Data, nil
 	}
+	if pic.dataOffset < 0 {
+		return nil, errors.New("meta.Picture.LoadData: offset of picture data unknown; underlying reader is not an io.Seeker")
+	}
+	if int64(pic.dataLen) > int64(MaxPictureSize) {
+		return nil, fmt.Errorf("meta.Picture.LoadData: picture data too large; expected <= %d bytes, got %d", MaxPictureSize, pic.dataLen)
+	}
+	data := make([]byte, pic.dataLen)
+	_, err := ra.ReadAt(data, pic.dataOffset)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("meta.Picture.LoadData: unable to read %d bytes of picture data at offset %d; %w", pic.dataLen, pic.dataOffset, err)
+	}
+	return data, nil
+}
 
-	return pic, nil
+// Marshal returns the binary representation of the Picture metadata block
+// body, as specified by the picture format described in ParsePicture. An error
+// is returned if the picture type is reserved, if the MIME type contains
+// non-printable characters, if the description is not valid UTF-8, or if the
+// encoded body does not fit within the 24-bit length of a metadata block
+// header. Pictures parsed with Options.PictureMetadataOnly cannot be marshaled
+// until their Data has been set.
+func (pic *Picture) Marshal() (buf []byte, err error) {
+	if pic.Data == nil && pic.dataLen > 0 {
+		return nil, errors.New("meta.Picture.Marshal: picture data not loaded; parsed with Options.PictureMetadataOnly")
+	}
+	if pic.Type > PicturePublisherLogo {
+		return nil, fmt.Errorf("meta.Picture.Marshal: reserved picture type: %d", pic.Type)
+	}
+	for _, r := range pic.MIME {
+		if r < 0x20 || r > 0x7E {
+			return nil, fmt.Errorf("meta.Picture.Marshal: invalid character in MIME type; expected >= 0x20 and <= 0x7E, got 0x%02X", r)
+		}
+	}
+	if !utf8.ValidString(pic.Desc) {
+		return nil, fmt.Errorf("meta.Picture.Marshal: invalid description %q; not valid UTF-8", pic.Desc)
+	}
+	// The length fields are computed from the MIME type, description and data,
+	// and therefore always match; the body must however fit within the 24-bit
+	// length of a metadata block header.
+	if n := 32 + len(pic.MIME) + len(pic.Desc) + len(pic.Data); n > 0xFFFFFF {
+		return nil, fmt.Errorf("meta.Picture.Marshal: body too large; expected <= %d bytes, got %d", 0xFFFFFF, n)
+	}
+	buf = make([]byte, 0, 32+len(pic.MIME)+len(pic.Desc)+len(pic.Data))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(pic.Type))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(pic.MIME)))
+	buf = append(buf, pic.MIME...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(pic.Desc)))
+	buf = append(buf, pic.Desc...)
+	buf = binary.BigEndian.AppendUint32(buf, pic.Width)
+	buf = binary.BigEndian.AppendUint32(buf, pic.Height)
+	buf = binary.BigEndian.AppendUint32(buf, pic.ColorDepth)
+	buf = binary.BigEndian.AppendUint32(buf, pic.ColorCount)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(pic.Data)))
+	buf = append(buf, pic.Data...)
+	return buf, nil
 }
@@ -1,25 +1,122 @@
 package meta
 
 import (
+	"encoding/binary"
+	"fmt"
 	"io"
+	"sync"
 )
 
-// readBuf is the local buffer used by readBytes.
-var readBuf = make([]byte, 4096)
+// maxPooledSize is the maximum capacity in bytes of scratch buffers which are
+// returned to the pool; larger buffers are left to the garbage collector to
+// avoid retaining memory after parsing large metadata blocks.
+const maxPooledSize = 64 * 1024
+
+// scratchPool is a pool of scratch buffers, which are reused between metadata
+// block parses to reduce generation of garbage. Metadata blocks may be parsed
+// concurrently, as each parse uses its own scratch buffer.
+var scratchPool = sync.Pool{
+	New: func() interface{} {
+		return &scratch{buf: make([]byte, 4096)}
+	},
+}
+
+// A scratch buffer is used to read the fields of a metadata block body.
+type scratch struct {
+	// The underlying buffer; initially 4096 bytes, it grows automatically if so
+	// required.
+	buf []byte
+}
+
+// getScratch returns a scratch buffer from the pool. Call scratch.release to
+// return it once the metadata block body has been parsed.
+func getScratch() *scratch {
+	return scratchPool.Get().(*scratch)
+}
+
+// release returns the scratch buffer to the pool. The scratch buffer, and any
+// data returned by it, must not be used after release.
+func (sc *scratch) release() {
+	if cap(sc.buf) > maxPooledSize {
+		return
+	}
+	scratchPool.Put(sc)
+}
 
 // readBytes reads and returns exactly n bytes from the provided io.Reader. The
-// local buffer is reused in between calls to reduce generation of garbage. It
-// is the callers responsibility to make a copy of the returned data.
+// returned data is only valid until the next read of the scratch buffer, so it
+// is the callers responsibility to make a copy of it.
 //
-// The local buffer is initially 4096 bytes and will grow automatically if so
-// required.
-func readBytes(r io.Reader, n int) ([]byte, error) {
-	if n > len(readBuf) {
-		readBuf = make([]byte, n)
+// The length n is typically read from the stream, and thus untrusted. To avoid
+// allocating memory for data which is not present, the scratch buffer grows in
+// chunks of at most maxPooledSize bytes as the data is read.
+func (sc *scratch) readBytes(r io.Reader, n int) ([]byte, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("meta.scratch.readBytes: invalid length; expected >= 0, got %d", n)
+	}
+	if n <= cap(sc.buf) || n <= maxPooledSize {
+		if n > cap(sc.buf) {
+			sc.buf = make([]byte, n)
+		}
+		buf := sc.buf[:n]
+		_, err := io.ReadFull(r, buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf, nil
+	}
+	buf := sc.buf[:0]
+	for len(buf) < n {
+		m := n - len(buf)
+		if m > maxPooledSize {
+			m = maxPooledSize
+		}
+		buf = append(buf, make([]byte, m)...)
+		sc.buf = buf
+		_, err := io.ReadFull(r, buf[len(buf)-m:])
+		if err != nil {
+			if err == io.EOF && len(buf) > m {
+				// Part of the data has already been read.
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
 	}
-	_, err := io.ReadFull(r, readBuf[:n])
+	return buf, nil
+}
+
+// readUint32LE reads and returns a little-endian uint32 from the provided
+// io.Reader. Unlike binary.Read, it does not allocate.
+func (sc *scratch) readUint32LE(r io.Reader) (uint32, error) {
+	buf, err := sc.readBytes(r, 4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(buf), nil
+}
+
+// readUint32BE reads and returns a big-endian uint32 from the provided
+// io.Reader. Unlike binary.Read, it does not allocate.
+func (sc *scratch) readUint32BE(r io.Reader) (uint32, error) {
+	buf, err := sc.readBytes(r, 4)
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(buf), nil
+}
+
+// An eofReader records if the end of its underlying reader has been reached.
+type eofReader struct {
+	// The underlying reader.
+	r io.Reader
+	// eof is set when the underlying reader returns io.EOF.
+	eof bool
+}
+
+func (er *eofReader) Read(p []byte) (n int, err error) {
+	n, err = er.r.Read(p)
+	if err == io.EOF {
+		er.eof = true
 	}
-	return readBuf[:n], nil
+	return n, err
 }
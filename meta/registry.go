@@ -0,0 +1,54 @@
+package meta
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// A BlockParser parses the body of a metadata block with a reserved block
+// type. It is passed a reader limited to the block body and the length in bytes
+// of the block body.
+type BlockParser func(r io.Reader, length int) (body interface{}, err error)
+
+// blockParsers maps from reserved block type numbers to their registered
+// parsers, protected by blockParsersMu.
+var (
+	blockParsersMu sync.RWMutex
+	blockParsers   = make(map[uint8]BlockParser)
+)
+
+// RegisterBlockParser registers a parser for the bodies of metadata blocks with
+// the provided reserved block type number, which must be between 7 and 126
+// inclusive. Block.Parse consults the registered parsers for reserved blocks,
+// and falls back to storing the raw bytes of the block body as a []byte; as it
+// does when a registered parser fails, if Options.TolerateUnknown is set. A
+// previously registered parser of the same block type number is replaced.
+//
+// Custom bodies are written by Block.WriteTo if they implement
+//
+//    Marshal() ([]byte, error)
+//
+// RegisterBlockParser may be called concurrently with itself and with the
+// parsing of metadata blocks. It panics if rawType is not a reserved block
+// type number, or if parse is nil.
+func RegisterBlockParser(rawType uint8, parse BlockParser) {
+	if rawType < 7 || rawType > 126 {
+		panic(fmt.Errorf("meta.RegisterBlockParser: invalid reserved block type number; expected >= 7 and <= 126, got %d", rawType))
+	}
+	if parse == nil {
+		panic("meta.RegisterBlockParser: nil block parser")
+	}
+	blockParsersMu.Lock()
+	defer blockParsersMu.Unlock()
+	blockParsers[rawType] = parse
+}
+
+// lookupBlockParser returns the registered parser of the provided reserved block
+// type number, and a boolean indicating if such a parser was registered.
+func lookupBlockParser(rawType uint8) (parse BlockParser, ok bool) {
+	blockParsersMu.RLock()
+	defer blockParsersMu.RUnlock()
+	parse, ok = blockParsers[rawType]
+	return parse, ok
+}
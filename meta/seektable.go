@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"sort"
 )
 
 // A SeekTable metadata block is an optional block for storing seek points. It
@@ -22,13 +23,20 @@ type SeekTable struct {
 	Points []SeekPoint
 }
 
-// A SeekPoint specifies the offset of a sample.
+// A SeekPoint specifies the offset of a sample. The fields correspond to the
+// sample number, stream offset and frame samples of a seek point, as named by
+// the FLAC format specification and metaflac.
+//
+// ref: http://flac.sourceforge.net/format.html#seekpoint
 type SeekPoint struct {
 	// Sample number of first sample in the target frame, or 0xFFFFFFFFFFFFFFFF
 	// for a placeholder point.
 	SampleNum uint64
 	// Offset (in bytes) from the first byte of the first frame header to the
-	// first byte of the target frame's header.
+	// first byte of the target frame's header. The offset is relative to the
+	// first audio frame, not to the start of the file; the file offset of the
+	// target frame is given by adding the offset of the first audio frame, as
+	// returned by flac.Stream.AudioOffset.
 	Offset uint64
 	// Number of samples in the target frame.
 	SampleCount uint16
@@ -39,6 +47,23 @@ type SeekPoint struct {
 // structure are undefined.
 const PlaceholderPoint = 0xFFFFFFFFFFFFFFFF
 
+// IsPlaceholder returns true if the seek point is a placeholder point, and
+// false otherwise. Placeholder points are ignored by decoders, and must occur
+// at the end of the seek table.
+func (point SeekPoint) IsPlaceholder() bool {
+	return point.SampleNum == PlaceholderPoint
+}
+
+// Placeholders returns the number of placeholder points of the seek table.
+func (st *SeekTable) Placeholders() (n int) {
+	for _, point := range st.Points {
+		if point.IsPlaceholder() {
+			n++
+		}
+	}
+	return n
+}
+
 // ParseSeekTable parses and returns a new SeekTable metadata block. The
 // provided io.Reader should limit the amount of data that can be read to
 // header.Length bytes.
@@ -59,19 +84,27 @@ const PlaceholderPoint = 0xFFFFFFFFFFFFFFFF
 //
 // ref: http://flac.sourceforge.net/format.html#metadata_block_seektable
 func ParseSeekTable(r io.Reader) (st *SeekTable, err error) {
+	sc := getScratch()
+	defer sc.release()
+
 	st = new(SeekTable)
 	var hasPrev bool
 	var prevSampleNum uint64
 	for {
-		var point SeekPoint
-		err = binary.Read(r, binary.BigEndian, &point)
+		// Seek point (size: 18 bytes).
+		buf, err := sc.readBytes(r, 18)
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
 			return nil, err
 		}
-		if hasPrev && point.SampleNum != PlaceholderPoint {
+		point := SeekPoint{
+			SampleNum:   binary.BigEndian.Uint64(buf[0:]),
+			Offset:      binary.BigEndian.Uint64(buf[8:]),
+			SampleCount: binary.BigEndian.Uint16(buf[16:]),
+		}
+		if hasPrev && !point.IsPlaceholder() {
 			// - Seek points within a table must be sorted in ascending order by
 			//   sample number.
 			// - Seek points within a table must be unique by sample number, with
@@ -91,3 +124,128 @@ func ParseSeekTable(r io.Reader) (st *SeekTable, err error) {
 	}
 	return st, nil
 }
+
+// maxSeekPoints is the maximum number of seek points of a seek table, as limited
+// by the 24-bit length of the metadata block header and the 18 byte size of each
+// seek point.
+const maxSeekPoints = 0xFFFFFF / 18
+
+// BuildSeekTable returns a new seek table with one seek point every interval
+// samples, starting at sample 0 and ending before totalSamples. The offsetFn
+// function is called for the sample number of each seek point, and should
+// return the offset (in bytes) from the first byte of the first frame header to
+// the first byte of the target frame's header, and the number of samples in the
+// target frame. An error is returned if the seek table would exceed 932067
+// seek points, the maximum that fits in a metadata block.
+func BuildSeekTable(totalSamples uint64, interval uint64, offsetFn func(sample uint64) (offset uint64, frameSamples uint16)) (st *SeekTable, err error) {
+	if totalSamples == 0 {
+		return nil, fmt.Errorf("meta.BuildSeekTable: invalid total number of samples; expected > 0, got %d", totalSamples)
+	}
+	if interval == 0 {
+		return nil, fmt.Errorf("meta.BuildSeekTable: invalid interval; expected > 0, got %d", interval)
+	}
+	n := (totalSamples-1)/interval + 1
+	if n > maxSeekPoints {
+		return nil, fmt.Errorf("meta.BuildSeekTable: too many seek points; expected <= %d, got %d", maxSeekPoints, n)
+	}
+	st = &SeekTable{Points: make([]SeekPoint, 0, n)}
+	for sample := uint64(0); sample < totalSamples; sample += interval {
+		offset, frameSamples := offsetFn(sample)
+		point := SeekPoint{SampleNum: sample, Offset: offset, SampleCount: frameSamples}
+		st.Points = append(st.Points, point)
+		if totalSamples-sample <= interval {
+			// Prevent overflow of sample.
+			break
+		}
+	}
+	return st, nil
+}
+
+// Search returns the seek point with the greatest sample number not exceeding
+// the provided sample number, and a boolean indicating if such a seek point was
+// present. Placeholder points are ignored.
+func (st *SeekTable) Search(sampleNum uint64) (point SeekPoint, ok bool) {
+	// Seek points are sorted in ascending order by sample number, and
+	// placeholder points occur at the end of the table.
+	i := sort.Search(len(st.Points), func(i int) bool {
+		return st.Points[i].SampleNum > sampleNum
+	})
+	for ; i > 0; i-- {
+		point = st.Points[i-1]
+		if !point.IsPlaceholder() {
+			return point, true
+		}
+	}
+	return SeekPoint{}, false
+}
+
+// Insert inserts the provided seek point into the seek table, keeping the seek
+// points sorted in ascending order by sample number. A seek point with the same
+// sample number as an existing seek point is inserted after it; use Dedup to
+// remove such duplicates. Placeholder points are appended to the end of the
+// table.
+func (st *SeekTable) Insert(point SeekPoint) {
+	// Placeholder points have the greatest possible sample number, and are
+	// therefore positioned after every other seek point.
+	i := sort.Search(len(st.Points), func(i int) bool {
+		return st.Points[i].SampleNum > point.SampleNum
+	})
+	if point.IsPlaceholder() {
+		i = len(st.Points)
+	}
+	st.Points = append(st.Points, SeekPoint{})
+	copy(st.Points[i+1:], st.Points[i:])
+	st.Points[i] = point
+}
+
+// Dedup removes seek points with duplicate sample numbers from the seek table,
+// keeping the first seek point of each sample number. The seek points are
+// assumed to be sorted in ascending order by sample number. Placeholder points
+// are left untouched.
+func (st *SeekTable) Dedup() {
+	points := st.Points[:0]
+	for _, point := range st.Points {
+		if !point.IsPlaceholder() && len(points) > 0 && point.SampleNum == points[len(points)-1].SampleNum {
+			continue
+		}
+		points = append(points, point)
+	}
+	st.Points = points
+}
+
+// Marshal returns the binary representation of the SeekTable metadata block
+// body, as specified by the seek table format described in ParseSeekTable.
+// Placeholder points are preserved as is. An error is returned if the seek
+// points are not sorted in ascending order by sample number, if the stream
+// offsets are not monotonically increasing, or if a placeholder point is
+// followed by a non-placeholder point.
+func (st *SeekTable) Marshal() (buf []byte, err error) {
+	buf = make([]byte, 0, 18*len(st.Points))
+	var prev *SeekPoint
+	var hasPlaceholder bool
+	for i := range st.Points {
+		point := &st.Points[i]
+		if point.IsPlaceholder() {
+			hasPlaceholder = true
+		} else {
+			if hasPlaceholder {
+				return nil, fmt.Errorf("meta.SeekTable.Marshal: invalid seek point %d; placeholder points must occur at the end of the table", i)
+			}
+			if prev != nil {
+				if prev.SampleNum == point.SampleNum {
+					return nil, fmt.Errorf("meta.SeekTable.Marshal: invalid seek point %d; sample number (%d) is not unique", i, point.SampleNum)
+				} else if prev.SampleNum > point.SampleNum {
+					return nil, fmt.Errorf("meta.SeekTable.Marshal: invalid seek point %d; sample number (%d) is not in ascending order", i, point.SampleNum)
+				}
+				if prev.Offset > point.Offset {
+					return nil, fmt.Errorf("meta.SeekTable.Marshal: invalid seek point %d; offset (%d) is not in ascending order", i, point.Offset)
+				}
+			}
+			prev = point
+		}
+		buf = binary.BigEndian.AppendUint64(buf, point.SampleNum)
+		buf = binary.BigEndian.AppendUint64(buf, point.Offset)
+		buf = binary.BigEndian.AppendUint16(buf, point.SampleCount)
+	}
+	return buf, nil
+}
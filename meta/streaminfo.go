@@ -1,8 +1,14 @@
 package meta
 
 import (
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"math"
+	"math/bits"
+	"strings"
+	"time"
 
 	"github.com/eaburns/bit"
 )
@@ -127,3 +133,207 @@ func ParseStreamInfo(r io.Reader) (si *StreamInfo, err error) {
 	}
 	return si, nil
 }
+
+// Samples returns the total number of inter-channel samples in the stream, or
+// 0 if the number of samples is not known.
+func (si *StreamInfo) Samples() uint64 {
+	return si.SampleCount
+}
+
+// Duration returns the duration of the stream, based on the total number of
+// samples and the sample rate. It returns 0 if the number of samples is not
+// known.
+func (si *StreamInfo) Duration() time.Duration {
+	if si.SampleCount == 0 || si.SampleRate == 0 {
+		return 0
+	}
+	// Split the computation to avoid overflow, since the max value of
+	// SampleCount is 0x0000000FFFFFFFFF.
+	rate := uint64(si.SampleRate)
+	secs := si.SampleCount / rate
+	rem := si.SampleCount % rate
+	return time.Duration(secs)*time.Second + time.Duration(rem*uint64(time.Second)/rate)
+}
+
+// Bitrate returns the average bitrate in bits per second of the audio frames
+// of the stream, rounded to the nearest integer, based on the provided total
+// size in bytes of the audio frames; e.g. the size of the file minus the
+// offset of the first audio frame. It returns 0 if the duration of the stream
+// is not known.
+func (si *StreamInfo) Bitrate(audioBytes int64) int {
+	if si.SampleCount == 0 || si.SampleRate == 0 || audioBytes <= 0 {
+		return 0
+	}
+	// bitrate = (audioBytes * 8) / (SampleCount / SampleRate), computed with a
+	// 128-bit intermediate product to avoid overflow and loss of precision.
+	hi, lo := bits.Mul64(uint64(audioBytes)*8, uint64(si.SampleRate))
+	// Round to nearest.
+	var carry uint64
+	lo, carry = bits.Add64(lo, si.SampleCount/2, 0)
+	hi += carry
+	if hi >= si.SampleCount {
+		// Overflow of the quotient; unreachable for valid streams.
+		return math.MaxInt
+	}
+	quo, _ := bits.Div64(hi, lo, si.SampleCount)
+	if quo > math.MaxInt {
+		return math.MaxInt
+	}
+	return int(quo)
+}
+
+// Validate verifies the invariants of the StreamInfo fields, as specified by
+// the FLAC format: block sizes between 16 and 65535 samples with the minimum
+// not exceeding the maximum, a sample rate between 1 and 655350 Hz, between 1
+// and 8 channels and between 4 and 32 bits per sample. The returned error lists
+// every violated invariant.
+func (si *StreamInfo) Validate() error {
+	var problems []string
+	if si.BlockSizeMin < 16 {
+		problems = append(problems, fmt.Sprintf("invalid min block size; expected >= 16, got %d", si.BlockSizeMin))
+	}
+	if si.BlockSizeMax < 16 {
+		problems = append(problems, fmt.Sprintf("invalid max block size; expected >= 16, got %d", si.BlockSizeMax))
+	}
+	if si.BlockSizeMin > si.BlockSizeMax {
+		problems = append(problems, fmt.Sprintf("invalid block sizes; min block size (%d) exceeds max block size (%d)", si.BlockSizeMin, si.BlockSizeMax))
+	}
+	if si.SampleRate < 1 || si.SampleRate > 655350 {
+		problems = append(problems, fmt.Sprintf("invalid sample rate; expected > 0 and <= 655350, got %d", si.SampleRate))
+	}
+	if si.ChannelCount < 1 || si.ChannelCount > 8 {
+		problems = append(problems, fmt.Sprintf("invalid number of channels; expected >= 1 and <= 8, got %d", si.ChannelCount))
+	}
+	if si.BitsPerSample < 4 || si.BitsPerSample > 32 {
+		problems = append(problems, fmt.Sprintf("invalid number of bits per sample; expected >= 4 and <= 32, got %d", si.BitsPerSample))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("meta.StreamInfo.Validate: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// channelLayouts maps from a number of channels to the conventional FLAC
+// channel order, which matches the default WAVEFORMATEXTENSIBLE channel masks
+// of libFLAC; e.g. 0x3F for 6 channels. The labels are those of SpeakerNames.
+// The following abbreviations are used:
+//    L:   left
+//    R:   right
+//    C:   center
+//    Lfe: low-frequency effects
+//    Ls:  left surround (side)
+//    Rs:  right surround (side)
+//    Cs:  center surround (back)
+//    Lb:  left back
+//    Rb:  right back
+//
+// ref: https://xiph.org/flac/format.html#frame_header
+var channelLayouts = [][]string{
+	1: {"Mono"},
+	2: {"L", "R"},
+	3: {"L", "R", "C"},
+	4: {"L", "R", "Lb", "Rb"},
+	5: {"L", "R", "C", "Lb", "Rb"},
+	6: {"L", "R", "C", "Lfe", "Lb", "Rb"},
+	7: {"L", "R", "C", "Lfe", "Cs", "Ls", "Rs"},
+	8: {"L", "R", "C", "Lfe", "Lb", "Rb", "Ls", "Rs"},
+}
+
+// ChannelLayout returns the conventional speaker labels of the channels of the
+// stream, in the conventional FLAC channel order; e.g. "L", "R" for stereo. It
+// returns nil for an invalid number of channels. The actual speaker
+// assignment of the stream may differ, as it is not stored by FLAC (unless
+// specified by e.g. a WAVEFORMATEXTENSIBLE_CHANNEL_MASK Vorbis comment).
+func (si *StreamInfo) ChannelLayout() []string {
+	if si.ChannelCount < 1 || int(si.ChannelCount) >= len(channelLayouts) {
+		return nil
+	}
+	layout := channelLayouts[si.ChannelCount]
+	return append([]string(nil), layout...)
+}
+
+// MD5String returns the MD5 signature of the unencoded audio data as a
+// lowercase hexadecimal string.
+func (si *StreamInfo) MD5String() string {
+	return hex.EncodeToString(si.MD5sum[:])
+}
+
+// HasMD5 returns true if the MD5 signature of the unencoded audio data is
+// present, and false if it is all zeros, which implies that the signature was
+// not computed by the encoder.
+func (si *StreamInfo) HasMD5() bool {
+	return si.MD5sum != [16]byte{}
+}
+
+// Marshal returns the binary representation of the StreamInfo metadata block
+// body, as specified by the stream info format described in ParseStreamInfo.
+// An error is returned if any field is out of range for its encoding.
+func (si *StreamInfo) Marshal() (buf []byte, err error) {
+	// Minimum block size.
+	if si.BlockSizeMin < 16 {
+		return nil, fmt.Errorf("meta.StreamInfo.Marshal: invalid min block size; expected >= 16, got %d", si.BlockSizeMin)
+	}
+
+	// Maximum block size.
+	if si.BlockSizeMax < 16 {
+		return nil, fmt.Errorf("meta.StreamInfo.Marshal: invalid max block size; expected >= 16 and <= 65535, got %d", si.BlockSizeMax)
+	}
+
+	// Minimum and maximum frame size (24 bits each).
+	if si.FrameSizeMin > 0xFFFFFF {
+		return nil, fmt.Errorf("meta.StreamInfo.Marshal: invalid min frame size; expected <= %d, got %d", 0xFFFFFF, si.FrameSizeMin)
+	}
+	if si.FrameSizeMax > 0xFFFFFF {
+		return nil, fmt.Errorf("meta.StreamInfo.Marshal: invalid max frame size; expected <= %d, got %d", 0xFFFFFF, si.FrameSizeMax)
+	}
+
+	// Sample rate (20 bits).
+	if si.SampleRate > 655350 || si.SampleRate == 0 {
+		return nil, fmt.Errorf("meta.StreamInfo.Marshal: invalid sample rate; expected > 0 and <= 655350, got %d", si.SampleRate)
+	}
+
+	// Channel count (3 bits).
+	if si.ChannelCount < 1 || si.ChannelCount > 8 {
+		return nil, fmt.Errorf("meta.StreamInfo.Marshal: invalid number of channels; expected >= 1 and <= 8, got %d", si.ChannelCount)
+	}
+
+	// Bits per sample (5 bits).
+	if si.BitsPerSample < 4 || si.BitsPerSample > 32 {
+		return nil, fmt.Errorf("meta.StreamInfo.Marshal: invalid number of bits per sample; expected >= 4 and <= 32, got %d", si.BitsPerSample)
+	}
+
+	// Sample count (36 bits).
+	if si.SampleCount > 0xFFFFFFFFF {
+		return nil, fmt.Errorf("meta.StreamInfo.Marshal: invalid sample count; expected <= %d, got %d", uint64(0xFFFFFFFFF), si.SampleCount)
+	}
+
+	buf = make([]byte, 34)
+	binary.BigEndian.PutUint16(buf[0:], si.BlockSizeMin)
+	binary.BigEndian.PutUint16(buf[2:], si.BlockSizeMax)
+	putUint24(buf[4:], si.FrameSizeMin)
+	putUint24(buf[7:], si.FrameSizeMax)
+
+	// The sample rate, channel count, bits per sample and sample count are
+	// packed into a single 64-bit big-endian value:
+	//    sample_rate     (20 bits)
+	//    channel_count   (3 bits)
+	//    bits_per_sample (5 bits)
+	//    sample_count    (36 bits)
+	x := uint64(si.SampleRate) << 44
+	x |= uint64(si.ChannelCount-1) << 41
+	x |= uint64(si.BitsPerSample-1) << 36
+	x |= si.SampleCount
+	binary.BigEndian.PutUint64(buf[10:], x)
+
+	// MD5 signature of the unencoded audio data.
+	copy(buf[18:], si.MD5sum[:])
+	return buf, nil
+}
+
+// putUint24 stores the 24 least significant bits of x in big-endian byte order
+// in the first three bytes of buf.
+func putUint24(buf []byte, x uint32) {
+	buf[0] = uint8(x >> 16)
+	buf[1] = uint8(x >> 8)
+	buf[2] = uint8(x)
+}
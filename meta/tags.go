@@ -0,0 +1,254 @@
+package meta
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CommonTags is a typed view of the most common Vorbis comment fields. A field
+// is left as its zero value if the corresponding entry is not present, or if
+// its value cannot be parsed.
+//
+// ref: https://www.xiph.org/vorbis/doc/v-comment.html
+type CommonTags struct {
+	// Track title; TITLE.
+	Title string
+	// Track artist; ARTIST.
+	Artist string
+	// Album title; ALBUM.
+	Album string
+	// Album artist; ALBUMARTIST or "ALBUM ARTIST".
+	AlbumArtist string
+	// Composer; COMPOSER.
+	Composer string
+	// Release date; DATE.
+	Date string
+	// Genre; GENRE.
+	Genre string
+	// Comment; COMMENT or DESCRIPTION.
+	Comment string
+	// Track number; TRACKNUMBER, either as "3" or "3/12".
+	TrackNumber int
+	// Total number of tracks; TRACKTOTAL, TOTALTRACKS or the total of a
+	// "3/12" TRACKNUMBER.
+	TrackTotal int
+	// Disc number; DISCNUMBER, either as "1" or "1/2".
+	DiscNumber int
+	// Total number of discs; DISCTOTAL, TOTALDISCS or the total of a "1/2"
+	// DISCNUMBER.
+	DiscTotal int
+}
+
+// Tags returns a typed view of the common fields of the Vorbis comment. Field
+// names are compared case-insensitively, and the first entry of each field is
+// used. The raw entries remain accessible through vc.Entries.
+func (vc *VorbisComment) Tags() CommonTags {
+	var tags CommonTags
+	tags.Title = vc.first("TITLE")
+	tags.Artist = vc.first("ARTIST")
+	tags.Album = vc.first("ALBUM")
+	tags.AlbumArtist = vc.first("ALBUMARTIST", "ALBUM ARTIST")
+	tags.Composer = vc.first("COMPOSER")
+	tags.Date = vc.first("DATE")
+	tags.Genre = vc.first("GENRE")
+	tags.Comment = vc.first("COMMENT", "DESCRIPTION")
+	tags.TrackNumber, tags.TrackTotal = parseNumTotal(vc.first("TRACKNUMBER"))
+	if total := parseNum(vc.first("TRACKTOTAL", "TOTALTRACKS")); total != 0 {
+		tags.TrackTotal = total
+	}
+	tags.DiscNumber, tags.DiscTotal = parseNumTotal(vc.first("DISCNUMBER"))
+	if total := parseNum(vc.first("DISCTOTAL", "TOTALDISCS")); total != 0 {
+		tags.DiscTotal = total
+	}
+	return tags
+}
+
+// ReplayGain holds the ReplayGain values of a stream, as stored in the
+// REPLAYGAIN_* Vorbis comment fields. A value is left as 0 if the
+// corresponding entry is not present, or if it cannot be parsed.
+//
+// ref: https://wiki.hydrogenaud.io/index.php?title=ReplayGain_2.0_specification
+type ReplayGain struct {
+	// Track gain in dB; REPLAYGAIN_TRACK_GAIN, e.g. "-6.48 dB".
+	TrackGain float64
+	// Linear track peak amplitude; REPLAYGAIN_TRACK_PEAK, e.g. "0.988831".
+	TrackPeak float64
+	// Album gain in dB; REPLAYGAIN_ALBUM_GAIN.
+	AlbumGain float64
+	// Linear album peak amplitude; REPLAYGAIN_ALBUM_PEAK.
+	AlbumPeak float64
+}
+
+// ReplayGain returns the parsed ReplayGain values of the Vorbis comment, and a
+// boolean indicating if any ReplayGain entry was present. The values are
+// parsed independently of locale, with an optional " dB" suffix for gains.
+func (vc *VorbisComment) ReplayGain() (rg *ReplayGain, ok bool) {
+	rg = new(ReplayGain)
+	fields := []struct {
+		name string
+		v    *float64
+	}{
+		{name: "REPLAYGAIN_TRACK_GAIN", v: &rg.TrackGain},
+		{name: "REPLAYGAIN_TRACK_PEAK", v: &rg.TrackPeak},
+		{name: "REPLAYGAIN_ALBUM_GAIN", v: &rg.AlbumGain},
+		{name: "REPLAYGAIN_ALBUM_PEAK", v: &rg.AlbumPeak},
+	}
+	for _, field := range fields {
+		value, found := vc.Get(field.name)
+		if !found {
+			continue
+		}
+		ok = true
+		*field.v = parseGain(value)
+	}
+	if !ok {
+		return nil, false
+	}
+	return rg, true
+}
+
+// parseGain parses a ReplayGain gain or peak value, with an optional
+// case-insensitive "dB" suffix; e.g. "-6.48 dB". It returns 0 if s is not a
+// valid value.
+func parseGain(s string) float64 {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && strings.EqualFold(s[len(s)-2:], "dB") {
+		s = strings.TrimSpace(s[:len(s)-2])
+	}
+	x, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return x
+}
+
+// first returns the value of the first entry with any of the provided names, in
+// order of preference, or the empty string if not present.
+func (vc *VorbisComment) first(names ...string) string {
+	for _, name := range names {
+		if value, ok := vc.Get(name); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// parseNumTotal parses a number with an optional total; e.g. "3" or "3/12".
+func parseNumTotal(s string) (num, total int) {
+	if pos := strings.IndexByte(s, '/'); pos != -1 {
+		return parseNum(s[:pos]), parseNum(s[pos+1:])
+	}
+	return parseNum(s), 0
+}
+
+// parseNum parses a non-negative decimal number, or returns 0 if s is not a
+// valid number.
+func parseNum(s string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// ChannelMask returns the speaker assignment of the channels of the stream, as
+// stored in the WAVEFORMATEXTENSIBLE_CHANNEL_MASK Vorbis comment field, and a
+// boolean indicating if a valid channel mask was present. The channel mask is
+// stored as a hexadecimal number of variable width, with an optional "0x"
+// prefix; e.g. "0x3F" for 5.1 surround sound. Use SpeakerNames to get the
+// speakers of the channel mask.
+func (vc *VorbisComment) ChannelMask() (mask uint32, ok bool) {
+	value, ok := vc.Get("WAVEFORMATEXTENSIBLE_CHANNEL_MASK")
+	if !ok {
+		return 0, false
+	}
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && value[0] == '0' && (value[1] == 'x' || value[1] == 'X') {
+		value = value[2:]
+	}
+	x, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(x), true
+}
+
+// speakerNames maps from the bits of a WAVEFORMATEXTENSIBLE channel mask to the
+// labels of their speakers, using the abbreviations of StreamInfo.ChannelLayout
+// where applicable. The following additional abbreviations are used:
+//    Lc:  left of center
+//    Rc:  right of center
+//    Tc:  top center
+//    Tfl: top front left
+//    Tfc: top front center
+//    Tfr: top front right
+//    Tbl: top back left
+//    Tbc: top back center
+//    Tbr: top back right
+//
+// ref: https://learn.microsoft.com/en-us/windows/win32/api/mmreg/ns-mmreg-waveformatextensible
+var speakerNames = [...]string{
+	"L",   // SPEAKER_FRONT_LEFT
+	"R",   // SPEAKER_FRONT_RIGHT
+	"C",   // SPEAKER_FRONT_CENTER
+	"Lfe", // SPEAKER_LOW_FREQUENCY
+	"Lb",  // SPEAKER_BACK_LEFT
+	"Rb",  // SPEAKER_BACK_RIGHT
+	"Lc",  // SPEAKER_FRONT_LEFT_OF_CENTER
+	"Rc",  // SPEAKER_FRONT_RIGHT_OF_CENTER
+	"Cs",  // SPEAKER_BACK_CENTER
+	"Ls",  // SPEAKER_SIDE_LEFT
+	"Rs",  // SPEAKER_SIDE_RIGHT
+	"Tc",  // SPEAKER_TOP_CENTER
+	"Tfl", // SPEAKER_TOP_FRONT_LEFT
+	"Tfc", // SPEAKER_TOP_FRONT_CENTER
+	"Tfr", // SPEAKER_TOP_FRONT_RIGHT
+	"Tbl", // SPEAKER_TOP_BACK_LEFT
+	"Tbc", // SPEAKER_TOP_BACK_CENTER
+	"Tbr", // SPEAKER_TOP_BACK_RIGHT
+}
+
+// SpeakerNames returns the labels of the speakers of the provided
+// WAVEFORMATEXTENSIBLE channel mask, in channel order; i.e. in order of
+// increasing bit position. For instance, the channel mask 0x3F of 5.1 surround
+// sound gives "L", "R", "C", "Lfe", "Lb", "Rb". Reserved bits are ignored.
+func SpeakerNames(mask uint32) []string {
+	var names []string
+	for i, name := range speakerNames {
+		if mask&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// lyricsNames specifies the Vorbis comment field names used to store embedded
+// lyrics, in order of preference. The iTunes ©lyr atom is commonly mapped to
+// LYRICS, and UNSYNCED LYRICS is used by foobar2000.
+var lyricsNames = []string{"LYRICS", "UNSYNCEDLYRICS", "UNSYNCED LYRICS"}
+
+// Lyrics returns the embedded unsynchronized lyrics of the Vorbis comment, and
+// a boolean indicating if lyrics were present. The LYRICS, UNSYNCEDLYRICS and
+// "UNSYNCED LYRICS" fields are checked in order, and names are compared
+// case-insensitively. Lines are separated as stored; typically by "\n" or
+// "\r\n".
+func (vc *VorbisComment) Lyrics() (text string, ok bool) {
+	for _, name := range lyricsNames {
+		if text, ok := vc.Get(name); ok {
+			return text, true
+		}
+	}
+	return "", false
+}
+
+// SetLyrics stores the provided text as the embedded lyrics of the Vorbis
+// comment, using the LYRICS field. Any existing lyrics fields recognized by
+// Lyrics are removed, so that conflicting lyrics are not left behind. The text
+// is stored verbatim, including line breaks.
+func (vc *VorbisComment) SetLyrics(text string) {
+	for _, name := range lyricsNames[1:] {
+		vc.Remove(name)
+	}
+	// Set only fails for invalid names.
+	_ = vc.Set(lyricsNames[0], text)
+}
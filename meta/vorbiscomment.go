@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"unicode/utf8"
 )
 
 // A VorbisComment metadata block stores a list of human-readable name/value
@@ -14,14 +15,34 @@ import (
 // block in a stream. In some external documentation, Vorbis comments are called
 // FLAC tags to lessen confusion.
 type VorbisComment struct {
-	Vendor  string
+	// Vendor string, which identifies the encoder; e.g. "reference libFLAC
+	// 1.3.2 20170101". It is stored exactly as read from the stream, and may be
+	// empty.
+	Vendor string
+	// Name/value pairs, in the order of the stream.
 	Entries []VorbisEntry
 }
 
 // A VorbisEntry is a name/value pair.
 type VorbisEntry struct {
-	Name  string
+	// Field name; e.g. "TITLE". Field names are case-insensitive.
+	Name string
+	// UTF-8 encoded field value.
 	Value string
+	// NoSeparator is set for malformed comment vectors without a '='
+	// separator, in which case the entire comment vector is stored in Name and
+	// Value is empty. Such entries are ignored by Get, GetAll and Remove, and
+	// are written back unchanged by Marshal, so that rewriting the Vorbis
+	// comment preserves them.
+	NoSeparator bool `json:",omitempty"`
+}
+
+// vector returns the comment vector of the entry; e.g. "TITLE=foo".
+func (entry VorbisEntry) vector() string {
+	if entry.NoSeparator {
+		return entry.Name
+	}
+	return entry.Name + "=" + entry.Value
 }
 
 // ParseVorbisComment parses and returns a new VorbisComment metadata block. The
@@ -45,15 +66,17 @@ type VorbisEntry struct {
 //
 // ref: http://flac.sourceforge.net/format.html#metadata_block_vorbis_comment
 func ParseVorbisComment(r io.Reader) (vc *VorbisComment, err error) {
+	sc := getScratch()
+	defer sc.release()
+
 	// Vendor length.
-	var vendorLen uint32
-	err = binary.Read(r, binary.LittleEndian, &vendorLen)
+	vendorLen, err := sc.readUint32LE(r)
 	if err != nil {
 		return nil, err
 	}
 
 	// Vendor string.
-	buf, err := readBytes(r, int(vendorLen))
+	buf, err := sc.readBytes(r, int(vendorLen))
 	if err != nil {
 		return nil, err
 	}
@@ -61,41 +84,244 @@ func ParseVorbisComment(r io.Reader) (vc *VorbisComment, err error) {
 	vc.Vendor = string(buf)
 
 	// Comment count.
-	var commentCount uint32
-	err = binary.Read(r, binary.LittleEndian, &commentCount)
+	commentCount, err := sc.readUint32LE(r)
 	if err != nil {
 		return nil, err
 	}
 
 	// Comments.
-	if commentCount > 0 {
-		vc.Entries = make([]VorbisEntry, commentCount)
-		for i := 0; i < len(vc.Entries); i++ {
-			// Vector length
-			var vectorLen uint32
-			err = binary.Read(r, binary.LittleEndian, &vectorLen)
-			if err != nil {
-				return nil, err
-			}
+	for i := uint32(0); i < commentCount; i++ {
+		// Vector length
+		vectorLen, err := sc.readUint32LE(r)
+		if err != nil {
+			return nil, err
+		}
+
+		// Vector string.
+		buf, err = sc.readBytes(r, int(vectorLen))
+		if err != nil {
+			return nil, err
+		}
+		vector := string(buf)
+		pos := strings.Index(vector, "=")
+		if pos == -1 {
+			// Keep invalid comment vectors without a '=' separator, so that
+			// they are not lost when the Vorbis comment is written back.
+			vc.Entries = append(vc.Entries, VorbisEntry{Name: vector, NoSeparator: true})
+			continue
+		}
+
+		// Comment.
+		entry := VorbisEntry{
+			Name:  vector[:pos],
+			Value: vector[pos+1:],
+		}
+		vc.Entries = append(vc.Entries, entry)
+	}
+	return vc, nil
+}
+
+// checkUTF8 reports invalid UTF-8 in the vendor string and entry values of the
+// Vorbis comment through opts.Warn, and replaces it with U+FFFD if
+// opts.SanitizeUTF8 is set.
+func (vc *VorbisComment) checkUTF8(opts *Options) {
+	sanitize := opts != nil && opts.SanitizeUTF8
+	if !utf8.ValidString(vc.Vendor) {
+		opts.warnf("meta.Block.ParseOptions: invalid UTF-8 in vendor string %q", vc.Vendor)
+		if sanitize {
+			vc.Vendor = strings.ToValidUTF8(vc.Vendor, "\uFFFD")
+		}
+	}
+	for i := range vc.Entries {
+		entry := &vc.Entries[i]
+		if utf8.ValidString(entry.Value) {
+			continue
+		}
+		opts.warnf("meta.Block.ParseOptions: invalid UTF-8 in value %q of Vorbis comment field %q", entry.Value, entry.Name)
+		if sanitize {
+			entry.Value = strings.ToValidUTF8(entry.Value, "\uFFFD")
+		}
+	}
+}
+
+// Get returns the value of the first entry with the provided name, and a
+// boolean indicating if such an entry was present. Names are compared
+// case-insensitively, as specified by the Vorbis comment specification.
+// Entries without a '=' separator are ignored.
+func (vc *VorbisComment) Get(name string) (value string, ok bool) {
+	for _, entry := range vc.Entries {
+		if !entry.NoSeparator && strings.EqualFold(entry.Name, name) {
+			return entry.Value, true
+		}
+	}
+	return "", false
+}
+
+// GetAll returns the values of every entry with the provided name, in the
+// order they are stored. Names are compared case-insensitively, as specified by
+// the Vorbis comment specification. Entries without a '=' separator are
+// ignored.
+func (vc *VorbisComment) GetAll(name string) (values []string) {
+	for _, entry := range vc.Entries {
+		if !entry.NoSeparator && strings.EqualFold(entry.Name, name) {
+			values = append(values, entry.Value)
+		}
+	}
+	return values
+}
+
+// Marshal returns the binary representation of the VorbisComment metadata
+// block body, as specified by the Vorbis comment format described in
+// ParseVorbisComment. An error is returned if the vendor string or any entry
+// value is not valid UTF-8, or if the encoded body does not fit within the
+// 24-bit length of a metadata block header.
+func (vc *VorbisComment) Marshal() (buf []byte, err error) {
+	// Vendor length and vendor string.
+	if !utf8.ValidString(vc.Vendor) {
+		return nil, fmt.Errorf("meta.VorbisComment.Marshal: invalid vendor string %q; not valid UTF-8", vc.Vendor)
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(vc.Vendor)))
+	buf = append(buf, vc.Vendor...)
+
+	// Comment count.
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(vc.Entries)))
+
+	// Comments.
+	for i, entry := range vc.Entries {
+		if !utf8.ValidString(entry.Value) {
+			return nil, fmt.Errorf("meta.VorbisComment.Marshal: invalid value of entry %d (%q); not valid UTF-8", i, entry.Name)
+		}
+		vector := entry.vector()
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(vector)))
+		buf = append(buf, vector...)
+		if len(buf) > 0xFFFFFF {
+			return nil, fmt.Errorf("meta.VorbisComment.Marshal: body too large at entry %d (%q); expected <= %d bytes, got %d", i, entry.Name, 0xFFFFFF, len(buf))
+		}
+	}
+	if len(buf) > 0xFFFFFF {
+		return nil, fmt.Errorf("meta.VorbisComment.Marshal: body too large; expected <= %d bytes, got %d", 0xFFFFFF, len(buf))
+	}
+	return buf, nil
+}
+
+// WriteTo writes the binary representation of the VorbisComment metadata block
+// body to w, as specified by the Vorbis comment format described in
+// ParseVorbisComment, without buffering the entire body. It returns the number
+// of bytes written. The Vorbis comment is validated as by Marshal before
+// anything is written.
+func (vc *VorbisComment) WriteTo(w io.Writer) (n int64, err error) {
+	// Validate the Vorbis comment and compute the length of its body.
+	if !utf8.ValidString(vc.Vendor) {
+		return 0, fmt.Errorf("meta.VorbisComment.WriteTo: invalid vendor string %q; not valid UTF-8", vc.Vendor)
+	}
+	length := 4 + len(vc.Vendor) + 4
+	for i, entry := range vc.Entries {
+		if !utf8.ValidString(entry.Value) {
+			return 0, fmt.Errorf("meta.VorbisComment.WriteTo: invalid value of entry %d (%q); not valid UTF-8", i, entry.Name)
+		}
+		length += 4 + len(entry.vector())
+		if length > 0xFFFFFF {
+			return 0, fmt.Errorf("meta.VorbisComment.WriteTo: body too large at entry %d (%q); expected <= %d bytes, got %d", i, entry.Name, 0xFFFFFF, length)
+		}
+	}
 
-			// Vector string.
-			buf, err = readBytes(r, int(vectorLen))
+	// Write the length-prefixed fields.
+	var buf [4]byte
+	write := func(data ...string) error {
+		for _, s := range data {
+			m, err := io.WriteString(w, s)
+			n += int64(m)
 			if err != nil {
-				return nil, err
-			}
-			vector := string(buf)
-			pos := strings.Index(vector, "=")
-			if pos == -1 {
-				return nil, fmt.Errorf("meta.ParseVorbisComment: invalid comment vector; no '=' present in: %q", vector)
+				return err
 			}
+		}
+		return nil
+	}
+	writeUint32 := func(x uint32) error {
+		binary.LittleEndian.PutUint32(buf[:], x)
+		m, err := w.Write(buf[:])
+		n += int64(m)
+		return err
+	}
 
-			// Comment.
-			entry := VorbisEntry{
-				Name:  vector[:pos],
-				Value: vector[pos+1:],
-			}
-			vc.Entries[i] = entry
+	// Vendor length and vendor string.
+	if err := writeUint32(uint32(len(vc.Vendor))); err != nil {
+		return n, err
+	}
+	if err := write(vc.Vendor); err != nil {
+		return n, err
+	}
+
+	// Comment count.
+	if err := writeUint32(uint32(len(vc.Entries))); err != nil {
+		return n, err
+	}
+
+	// Comments.
+	for _, entry := range vc.Entries {
+		vector := entry.vector()
+		if err := writeUint32(uint32(len(vector))); err != nil {
+			return n, err
+		}
+		if err := write(vector); err != nil {
+			return n, err
 		}
 	}
-	return vc, nil
+	return n, nil
+}
+
+// Set removes every entry with the provided name and appends a single entry
+// with the provided name and value. Names are compared case-insensitively. An
+// error is returned if the name is invalid.
+func (vc *VorbisComment) Set(name, value string) error {
+	if !ValidFieldName(name) {
+		return fmt.Errorf("meta.VorbisComment.Set: invalid entry name %q", name)
+	}
+	vc.Remove(name)
+	vc.Entries = append(vc.Entries, VorbisEntry{Name: name, Value: value})
+	return nil
+}
+
+// Add appends an entry with the provided name and value, keeping any existing
+// entries with the same name. An error is returned if the name is invalid.
+func (vc *VorbisComment) Add(name, value string) error {
+	if !ValidFieldName(name) {
+		return fmt.Errorf("meta.VorbisComment.Add: invalid entry name %q", name)
+	}
+	vc.Entries = append(vc.Entries, VorbisEntry{Name: name, Value: value})
+	return nil
+}
+
+// Remove removes every entry with the provided name and returns the number of
+// removed entries. Names are compared case-insensitively. Entries without a '='
+// separator are never removed.
+func (vc *VorbisComment) Remove(name string) (n int) {
+	entries := vc.Entries[:0]
+	for _, entry := range vc.Entries {
+		if !entry.NoSeparator && strings.EqualFold(entry.Name, name) {
+			n++
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	vc.Entries = entries
+	return n
+}
+
+// ValidFieldName returns true if the provided entry name is a valid Vorbis
+// comment field name, and false otherwise. A field name must be non-empty and
+// may only contain the characters 0x20 through 0x7D, excluding 0x3D ('=').
+//
+// ref: https://www.xiph.org/vorbis/doc/v-comment.html
+func ValidFieldName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < 0x20 || c > 0x7D || c == '=' {
+			return false
+		}
+	}
+	return true
 }
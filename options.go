@@ -0,0 +1,106 @@
+package flac
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/mewkiz/flac/meta"
+)
+
+// Options specifies optional behaviour of ParseWithOptions. A nil *Options is
+// equivalent to the zero value, which parses streams the same way as Parse.
+type Options struct {
+	// SkipID3v2 skips an ID3v2 tag preceding the "fLaC" signature. Such tags
+	// are not permitted by the FLAC format, but are prepended by some taggers.
+	SkipID3v2 bool
+	// Options used to parse the metadata block bodies.
+	meta.Options
+}
+
+// ParseWithOptions reads from the provided io.Reader and returns a FLAC
+// bitstream with all metadata blocks parsed, except for the bodies of padding
+// blocks, using the provided parse options. The reader is left positioned at
+// the first audio frame.
+func ParseWithOptions(r io.Reader, opts *Options) (s *Stream, err error) {
+	if opts == nil {
+		opts = new(Options)
+	}
+
+	// Verify "fLaC" signature (size: 4 bytes), optionally preceded by an ID3v2
+	// tag.
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(r, buf)
+	if err != nil {
+		return nil, err
+	}
+	var offset int64
+	if opts.SkipID3v2 && string(buf[:3]) == "ID3" {
+		offset, err = skipID3v2(r, buf)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.ReadFull(r, buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = checkMarker(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	s = &Stream{r: r, audioOffset: offset + int64(len(signature))}
+	err = s.parseBlocks(context.Background(), meta.TypeAll, &opts.Options)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// skipID3v2 skips the ID3v2 tag of the provided reader, the first 4 bytes of
+// which have already been read into buf. It returns the total size of the ID3v2
+// tag in bytes.
+//
+// ID3v2 header format (pseudo code):
+//
+//    type ID3V2_HEADER struct {
+//       signature [3]byte // "ID3"
+//       version   [2]byte
+//       flags     uint8
+//       size      [4]byte // syncsafe integer; 7 bits per byte.
+//    }
+//
+// ref: https://id3.org/id3v2.4.0-structure
+func skipID3v2(r io.Reader, buf []byte) (n int64, err error) {
+	var hdr [10]byte
+	copy(hdr[:], buf)
+	_, err = io.ReadFull(r, hdr[len(buf):])
+	if err != nil {
+		return 0, err
+	}
+
+	// Size of the tag, excluding the header and footer.
+	var size int64
+	for _, b := range hdr[6:10] {
+		if b&0x80 != 0 {
+			return 0, fmt.Errorf("flac.skipID3v2: invalid ID3v2 tag size; syncsafe byte 0x%02X has its most significant bit set", b)
+		}
+		size = size<<7 | int64(b)
+	}
+	// A footer is present if bit 4 of the flags is set.
+	if hdr[5]&0x10 != 0 {
+		size += 10
+	}
+
+	if rs, ok := r.(io.Seeker); ok {
+		_, err = rs.Seek(size, io.SeekCurrent)
+	} else {
+		_, err = io.CopyN(ioutil.Discard, r, size)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(hdr)) + size, nil
+}
@@ -0,0 +1,55 @@
+package flac
+
+import (
+	"context"
+	"io"
+)
+
+// newCtxReader returns a reader which reads from r until ctx is cancelled, at
+// which point the error of ctx is returned. The returned reader implements
+// io.Seeker if r does, so that metadata block bodies may still be skipped by
+// seeking. If ctx can never be cancelled, r is returned as is.
+func newCtxReader(ctx context.Context, r io.Reader) io.Reader {
+	if ctx.Done() == nil {
+		return r
+	}
+	cr := &ctxReader{ctx: ctx, r: r}
+	if rs, ok := r.(io.ReadSeeker); ok {
+		return &ctxReadSeeker{ctxReader: cr, s: rs}
+	}
+	return cr
+}
+
+// A ctxReader is a reader which is bound by a context.
+type ctxReader struct {
+	// The context of read operations.
+	ctx context.Context
+	// The underlying reader.
+	r io.Reader
+}
+
+// Read reads from the underlying reader, unless the context has been
+// cancelled.
+func (cr *ctxReader) Read(p []byte) (n int, err error) {
+	err = cr.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// A ctxReadSeeker is a seekable reader which is bound by a context.
+type ctxReadSeeker struct {
+	*ctxReader
+	// The underlying seeker.
+	s io.Seeker
+}
+
+// Seek seeks the underlying seeker, unless the context has been cancelled.
+func (cs *ctxReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	err := cs.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+	return cs.s.Seek(offset, whence)
+}
@@ -0,0 +1,94 @@
+package flac
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/mewkiz/flac/meta"
+)
+
+// A ScanResult is the result of parsing a FLAC file found by ScanDir.
+type ScanResult struct {
+	// Path of the FLAC file.
+	Path string
+	// The parsed FLAC bitstream, or nil on error. The underlying file of the
+	// stream is closed once its metadata blocks have been parsed.
+	Stream *Stream
+	// Error encountered while locating or parsing the FLAC file.
+	Err error
+}
+
+// ScanDir walks the file tree rooted at root and parses the metadata blocks of
+// every FLAC file (with the ".flac" extension) concurrently, using the specified
+// number of workers; or one worker per CPU if workers <= 0. The bodies of
+// metadata blocks whose type is not present in the provided types bitfield are
+// skipped, as by ParseBlocks.
+//
+// The results are sent on the returned channel, in no particular order, which
+// is closed once every file has been parsed. Errors encountered while walking
+// the file tree are sent as results with a nil stream. The caller must receive
+// from the channel until it is closed; the workers block on sending results,
+// so abandoning the channel early leaks the goroutines of the walk and of the
+// workers.
+func ScanDir(root string, types meta.BlockType, workers int) (<-chan ScanResult, error) {
+	_, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	paths := make(chan string)
+	results := make(chan ScanResult)
+	go func() {
+		defer close(paths)
+		filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				results <- ScanResult{Path: path, Err: err}
+				return nil
+			}
+			if !fi.Mode().IsRegular() || !strings.EqualFold(filepath.Ext(path), ".flac") {
+				return nil
+			}
+			paths <- path
+			return nil
+		})
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				s, err := scanFile(path, types)
+				results <- ScanResult{Path: path, Stream: s, Err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results, nil
+}
+
+// scanFile parses the specified metadata blocks of the provided FLAC file, and
+// closes the file.
+func scanFile(path string, types meta.BlockType) (s *Stream, err error) {
+	s, err = Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	err = s.ParseBlocks(types)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
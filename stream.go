@@ -0,0 +1,58 @@
+package flac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mewkiz/flac/meta"
+)
+
+// StopParsing may be returned by the callback of WalkBlocks to stop parsing
+// before the last metadata block has been reached. WalkBlocks never returns
+// StopParsing.
+var StopParsing = errors.New("flac: stop parsing")
+
+// WalkBlocks reads the "fLaC" signature and the metadata blocks of the
+// provided io.Reader, and calls fn with each metadata block as it is parsed,
+// without retaining the blocks; the memory usage is thus bounded by the size
+// of the largest block. The bodies of padding blocks are skipped, as by Parse.
+//
+// Parsing stops after the last metadata block, in which case the reader is
+// left positioned at the first audio frame, or when fn returns an error. If fn
+// returns StopParsing, or an error wrapping it, WalkBlocks returns nil and the
+// reader is left positioned after the current metadata block; any other error
+// is returned as is.
+func WalkBlocks(r io.Reader, fn func(*meta.Block) error) error {
+	err := VerifyMarker(r)
+	if err != nil {
+		return err
+	}
+	for isFirst := true; ; isFirst = false {
+		block, err := meta.NewBlock(r)
+		if err != nil {
+			return err
+		}
+		if isFirst && block.Header.BlockType != meta.TypeStreamInfo {
+			return fmt.Errorf("flac.WalkBlocks: %w; expected %v, got %v", ErrStreamInfoNotFirst, meta.TypeStreamInfo, block.Header.BlockType)
+		}
+		if block.Header.BlockType == meta.TypePadding {
+			_, err = block.Skip()
+		} else {
+			err = block.Parse()
+		}
+		if err != nil {
+			return err
+		}
+		err = fn(block)
+		if errors.Is(err, StopParsing) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if block.Header.IsLast {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package flac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mewkiz/flac/meta"
+)
+
+// Errors returned by ParseStrict for streams which do not comply with the FLAC
+// format.
+var (
+	// ErrDuplicateStreamInfo is returned when a stream contains more than one
+	// StreamInfo metadata block.
+	ErrDuplicateStreamInfo = errors.New("flac: duplicate StreamInfo metadata block")
+	// ErrReservedBlockType is returned when a stream contains a metadata block
+	// of a reserved block type.
+	ErrReservedBlockType = errors.New("flac: reserved metadata block type")
+	// ErrInvalidFieldName is returned when a stream contains a Vorbis comment
+	// with an invalid field name.
+	ErrInvalidFieldName = errors.New("flac: invalid Vorbis comment field name")
+)
+
+// ParseStrict reads from the provided io.Reader and returns a FLAC bitstream
+// with all metadata blocks parsed, including the bodies of padding blocks. An
+// error is returned if the metadata does not strictly comply with the FLAC
+// format; i.e. unless:
+//    - the StreamInfo block is the first block and is present exactly once,
+//    - the IsLast flag is set exactly once, on the final block,
+//    - no block has a reserved block type,
+//    - the bodies of padding blocks contain only zeroes, and
+//    - the field names of Vorbis comments are valid, and followed by a '='
+//      separator.
+//
+// The reader is left positioned at the first audio frame.
+func ParseStrict(r io.Reader) (s *Stream, err error) {
+	s, err = NewStream(r)
+	if err != nil {
+		return nil, err
+	}
+	// Stream.ParseBlocks verifies that the StreamInfo block is first, and stops
+	// at the first block with the IsLast flag set; padding bodies are verified
+	// by meta.VerifyPadding.
+	err = s.ParseBlocks(meta.TypeAllStrict | meta.TypeReserved)
+	if err != nil {
+		return nil, err
+	}
+	for i, block := range s.Blocks {
+		switch body := block.Body.(type) {
+		case *meta.StreamInfo:
+			if i != 0 {
+				return nil, fmt.Errorf("flac.ParseStrict: %w; block %d", ErrDuplicateStreamInfo, i)
+			}
+		case *meta.VorbisComment:
+			for j, entry := range body.Entries {
+				if entry.NoSeparator {
+					return nil, fmt.Errorf("flac.ParseStrict: %w; vector %q of entry %d in block %d lacks a '=' separator", ErrInvalidFieldName, entry.Name, j, i)
+				}
+				if !meta.ValidFieldName(entry.Name) {
+					return nil, fmt.Errorf("flac.ParseStrict: %w; %q of entry %d in block %d", ErrInvalidFieldName, entry.Name, j, i)
+				}
+			}
+		}
+		if block.Header.BlockType == meta.TypeReserved {
+			return nil, fmt.Errorf("flac.ParseStrict: %w; block type number %d of block %d", ErrReservedBlockType, block.Header.RawType, i)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,85 @@
+package flac
+
+import (
+	"github.com/mewkiz/flac/meta"
+)
+
+// A Tagger provides access to the Vorbis comment tags of a FLAC file, without
+// exposing the underlying metadata blocks. Tags are read by OpenTagger, edited
+// in memory and written back to the file by Tagger.Save.
+type Tagger struct {
+	// Path of the FLAC file.
+	path string
+	// Vorbis comment of the file; created by OpenTagger if the file contains
+	// none.
+	vc *meta.VorbisComment
+}
+
+// OpenTagger reads the Vorbis comment tags of the provided FLAC file. An empty
+// Vorbis comment is created if the file contains none, which is added to the
+// file by Tagger.Save. The file is not kept open.
+func OpenTagger(filePath string) (*Tagger, error) {
+	s, err := Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	err = s.ParseBlocks(meta.TypeVorbisComment)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tagger{path: filePath}
+	if block, ok := s.FindBlock(meta.TypeVorbisComment); ok {
+		t.vc = block.Body.(*meta.VorbisComment)
+	} else {
+		t.vc = new(meta.VorbisComment)
+	}
+	return t, nil
+}
+
+// Get returns the value of the first tag with the provided name, and a boolean
+// indicating if such a tag was present. Names are compared case-insensitively.
+func (t *Tagger) Get(name string) (value string, ok bool) {
+	return t.vc.Get(name)
+}
+
+// Set replaces every tag with the provided name by a single tag with the
+// provided value. An error is returned if the name is invalid.
+func (t *Tagger) Set(name, value string) error {
+	return t.vc.Set(name, value)
+}
+
+// Delete removes every tag with the provided name, and returns the number of
+// tags removed.
+func (t *Tagger) Delete(name string) int {
+	return t.vc.Remove(name)
+}
+
+// All returns a copy of every tag, in order.
+func (t *Tagger) All() []meta.VorbisEntry {
+	return append([]meta.VorbisEntry(nil), t.vc.Entries...)
+}
+
+// Save writes the tags back to the file, using UpdateFile; the file is
+// rewritten in place if the padding of the file allows it. The Vorbis comment
+// block is added to the file if not present, and the other metadata blocks and
+// the audio frames are preserved.
+func (t *Tagger) Save() error {
+	return UpdateFile(t.path, func(s *Stream) error {
+		if block, ok := s.FindBlock(meta.TypeVorbisComment); ok {
+			block.Body = t.vc
+			return nil
+		}
+		hdr := &meta.BlockHeader{
+			BlockType: meta.TypeVorbisComment,
+			RawType:   4,
+		}
+		block := &meta.Block{Header: hdr, Body: t.vc}
+		var err error
+		hdr.Length, err = block.MarshaledLen()
+		if err != nil {
+			return err
+		}
+		return s.AddBlock(block)
+	})
+}
@@ -0,0 +1,193 @@
+package flac
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mewkiz/flac/meta"
+)
+
+// UpdateFile parses the metadata blocks of the provided file, calls modify to
+// edit them and writes the modified metadata blocks back to the file. The
+// bodies of padding blocks are not parsed, and the audio frames are never
+// modified.
+//
+// The metadata is rewritten in place by WriteMetadata when the modified
+// metadata fits within the size of the original metadata. The length of the
+// last padding block is adjusted to absorb the difference, and a new padding
+// block is appended if the stream contains none. Otherwise, the file is
+// rewritten by writing the modified metadata followed by a copy of the original
+// audio frames to a temporary file, which then replaces the original file.
+func UpdateFile(filePath string, modify func(*Stream) error) (err error) {
+	f, err := os.OpenFile(filePath, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := f.Close(); err == nil {
+			err = e
+		}
+	}()
+
+	// Parse metadata blocks.
+	s, err := NewStream(f)
+	if err != nil {
+		return err
+	}
+	err = s.ParseBlocks(meta.TypeAll)
+	if err != nil {
+		return err
+	}
+	audioOffset := s.AudioOffset()
+
+	// Modify metadata blocks.
+	err = modify(s)
+	if err != nil {
+		return err
+	}
+
+	// Write the modified metadata in place.
+	err = WriteMetadata(f, s)
+	if !errors.Is(err, ErrMetadataTooLarge) {
+		return err
+	}
+
+	// Rewrite the file, copying the audio frames unchanged.
+	buf, err := encodeMetadata(s.Blocks)
+	if err != nil {
+		return err
+	}
+	return rewriteFile(filePath, f, buf, audioOffset)
+}
+
+// ErrMetadataTooLarge is returned by WriteMetadata when the modified metadata
+// blocks do not fit within the size of the original metadata.
+var ErrMetadataTooLarge = errors.New("flac: metadata does not fit in place")
+
+// WriteMetadata overwrites the metadata blocks of the FLAC file underlying rws
+// with the metadata blocks of s, which was parsed from the same file, leaving
+// the "fLaC" signature and the audio frames unchanged. The metadata is written
+// in place, starting at offset 4, and must fit within the size of the original
+// metadata; i.e. s.AudioOffset() bytes including the signature. The length of
+// the last padding block is adjusted to absorb the difference, and a new
+// padding block is appended if the stream contains none.
+//
+// An error wrapping ErrMetadataTooLarge is returned, and nothing is written, if
+// the modified metadata does not fit in place; e.g. if it is larger than the
+// original metadata, or smaller by less than the 4 bytes required by the header
+// of a new padding block.
+func WriteMetadata(rws io.ReadWriteSeeker, s *Stream) error {
+	audioOffset := s.AudioOffset()
+	buf, err := encodeMetadata(s.Blocks)
+	if err != nil {
+		return err
+	}
+
+	// Adjust the padding to make the modified metadata fit in place.
+	if diff := int(audioOffset) - len(buf); diff != 0 {
+		if padding := lastPadding(s.Blocks); padding != nil {
+			if padding.SetPaddingLength(padding.Header.Length+diff) == nil {
+				buf, err = encodeMetadata(s.Blocks)
+				if err != nil {
+					return err
+				}
+			}
+		} else if diff >= 4 {
+			// Each metadata block requires a 4 byte header.
+			padding := meta.NewPadding(diff - 4)
+			blocks := append(s.Blocks[:len(s.Blocks):len(s.Blocks)], padding)
+			if b, err := encodeMetadata(blocks); err == nil {
+				s.Blocks = blocks
+				buf = b
+			}
+		}
+	}
+	if int64(len(buf)) != audioOffset {
+		return fmt.Errorf("flac.WriteMetadata: %w; expected at most %d bytes, got %d",
+			ErrMetadataTooLarge, audioOffset, len(buf))
+	}
+
+	// Skip the "fLaC" signature.
+	_, err = rws.Seek(4, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	_, err = rws.Write(buf[4:])
+	return err
+}
+
+// encodeMetadata returns the "fLaC" signature followed by the encoded metadata
+// blocks.
+func encodeMetadata(blocks []*meta.Block) (buf []byte, err error) {
+	b := new(bytes.Buffer)
+	enc := NewEncoder(b)
+	for _, block := range blocks {
+		enc.AddBlock(block)
+	}
+	err = enc.Close()
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// lastPadding returns the last padding block of the provided metadata blocks,
+// or nil if not present.
+func lastPadding(blocks []*meta.Block) *meta.Block {
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if blocks[i].Header.BlockType == meta.TypePadding {
+			return blocks[i]
+		}
+	}
+	return nil
+}
+
+// rewriteFile writes the provided metadata followed by the audio frames of f,
+// which start at audioOffset, to a temporary file and replaces the file at
+// filePath with it.
+func rewriteFile(filePath string, f *os.File, metadata []byte,
+	audioOffset int64) (err error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	pattern := "." + filepath.Base(filePath) + ".tmp"
+	tmp, err := ioutil.TempFile(filepath.Dir(filePath), pattern)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	_, err = tmp.Write(metadata)
+	if err != nil {
+		return err
+	}
+	audio := io.NewSectionReader(f, audioOffset, fi.Size()-audioOffset)
+	_, err = io.Copy(tmp, audio)
+	if err != nil {
+		return err
+	}
+	err = tmp.Chmod(fi.Mode())
+	if err != nil {
+		return err
+	}
+	err = tmp.Sync()
+	if err != nil {
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filePath)
+}